@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/openware/kaigara/pkg/config"
+	enc "github.com/openware/kaigara/pkg/encryptor/types"
+	"github.com/openware/kaigara/pkg/storage"
+)
+
+func encryptCmd(args []string) error {
+	return cryptCmd(args, kaiencryptRun)
+}
+
+func decryptCmd(args []string) error {
+	return cryptCmd(args, kaidecryptRun)
+}
+
+func cryptCmd(args []string, run func(enc.Encryptor, string, []string, io.Reader, io.Writer) error) error {
+	appName := conf.AppNames
+	if appName == "" || strings.Contains(appName, ",") {
+		return fmt.Errorf("a single app name must be set, got '%s'", appName)
+	}
+
+	encryptor, err := storage.NewEncryptor(conf)
+	if err != nil {
+		return fmt.Errorf("encryptor init failed: %s", err)
+	}
+	encryptors = append(encryptors, encryptor)
+
+	return run(encryptor, encryptorKeyName(conf, appName), args, os.Stdin, os.Stdout)
+}
+
+// encryptorKeyName returns the key name the configured storage driver passes to the encryptor for the app
+func encryptorKeyName(conf *config.KaigaraConfig, appName string) string {
+	if conf.Storage == "k8s" {
+		return appName
+	}
+
+	return fmt.Sprintf("%s_kaigara_%s", conf.DeploymentID, appName)
+}
+
+// kaiencryptRun encrypts each value, or the whole input if no value is given, and prints one ciphertext per line
+func kaiencryptRun(encryptor enc.Encryptor, keyName string, values []string, in io.Reader, out io.Writer) error {
+	return cryptValues(encryptor.Encrypt, keyName, values, in, out)
+}
+
+// kaidecryptRun decrypts each value, or the whole input if no value is given, and prints one plaintext per line
+func kaidecryptRun(encryptor enc.Encryptor, keyName string, values []string, in io.Reader, out io.Writer) error {
+	return cryptValues(encryptor.Decrypt, keyName, values, in, out)
+}
+
+func cryptValues(crypt func(string, string) (string, error), keyName string, values []string, in io.Reader, out io.Writer) error {
+	if len(values) == 0 {
+		input, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+
+		// Drop the line ending added by echo or a heredoc
+		value := strings.TrimSuffix(string(input), "\n")
+		values = []string{strings.TrimSuffix(value, "\r")}
+	}
+
+	for _, value := range values {
+		res, err := crypt(value, keyName)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(out, res)
+	}
+
+	return nil
+}
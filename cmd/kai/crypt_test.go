@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/config"
+	"github.com/openware/kaigara/pkg/encryptor/aes"
+)
+
+func TestKaiencryptDecryptRun(t *testing.T) {
+	encryptor, err := aes.NewAESEncryptor([]byte("1234567890123456"))
+	assert.NoError(t, err)
+
+	var ciphertexts bytes.Buffer
+	err = kaiencryptRun(encryptor, "odax_kaigara_finex", []string{"changeme", "bonjour"}, nil, &ciphertexts)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(ciphertexts.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "kgr:v1:aes:"))
+
+	var plaintexts bytes.Buffer
+	err = kaidecryptRun(encryptor, "odax_kaigara_finex", nil, strings.NewReader(lines[1]+"\n"), &plaintexts)
+	assert.NoError(t, err)
+	assert.Equal(t, "bonjour\n", plaintexts.String())
+
+	err = kaidecryptRun(encryptor, "odax_kaigara_finex", []string{"kgr:v1:aes::broken"}, nil, &plaintexts)
+	assert.Error(t, err)
+}
+
+func TestEncryptorKeyName(t *testing.T) {
+	assert.Equal(t, "odax_kaigara_finex", encryptorKeyName(&config.KaigaraConfig{Storage: "vault", DeploymentID: "odax"}, "finex"))
+	assert.Equal(t, "odax_kaigara_finex", encryptorKeyName(&config.KaigaraConfig{Storage: "sql", DeploymentID: "odax"}, "finex"))
+	assert.Equal(t, "finex", encryptorKeyName(&config.KaigaraConfig{Storage: "k8s", DeploymentID: "odax"}, "finex"))
+}
@@ -2,10 +2,10 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"strings"
 
+	"github.com/openware/kaigara/pkg/logger"
 	"github.com/openware/kaigara/types"
 )
 
@@ -68,14 +68,14 @@ func kaidelRun(ss types.Storage, entryPattern string) error {
 						return err
 					}
 
-					log.Printf("INF: deleted %s.%s.%s\n", appName, scope, entry)
+					logger.Info("entry deleted", logger.F("app", appName), logger.F("scope", scope), logger.F("entry", entry))
 				}
 			} else {
 				if err := ss.DeleteEntry(appName, scope, varName); err != nil {
 					return err
 				}
 
-				log.Printf("INF: deleted %s.%s.%s\n", appName, scope, varName)
+				logger.Info("entry deleted", logger.F("app", appName), logger.F("scope", scope), logger.F("entry", varName))
 			}
 
 			if err := ss.Write(appName, scope); err != nil {
@@ -3,12 +3,12 @@ package main
 import (
 	"bytes"
 	"fmt"
-	"log"
 	"os"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/openware/kaigara/pkg/logger"
 	"github.com/openware/kaigara/types"
 )
 
@@ -25,7 +25,7 @@ func dumpCmd() error {
 		return err
 	}
 
-	log.Printf("INF: dump saved into %s\n", SecretsPath)
+	logger.Info("dump saved", logger.F("path", SecretsPath))
 	return nil
 }
 
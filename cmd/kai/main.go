@@ -1,12 +1,13 @@
 package main
 
 import (
-	"log"
 	"os"
 
 	"github.com/openware/pkg/kli"
 
 	"github.com/openware/kaigara/pkg/config"
+	enc "github.com/openware/kaigara/pkg/encryptor/types"
+	"github.com/openware/kaigara/pkg/logger"
 	"github.com/openware/kaigara/pkg/storage"
 	"github.com/openware/kaigara/types"
 )
@@ -16,6 +17,12 @@ var conf *config.KaigaraConfig
 var Version = "dev"
 var SecretsPath = "outputs.yaml"
 
+// encryptors are closed when kai exits, which stops the command of a persistent exec encryptor
+var encryptors []enc.Encryptor
+
+// kaiLogger is the logger configured at start, without the fields of the command
+var kaiLogger = logger.Default()
+
 func main() {
 	var err error
 
@@ -31,6 +38,11 @@ func main() {
 		panic(err)
 	}
 
+	if kaiLogger, err = config.NewLogger(conf, "Kai"); err != nil {
+		panic(err)
+	}
+	logger.SetDefault(kaiLogger)
+
 	cli := kli.NewCli("kai", "Kaigara CLI tool for managing secrets", Version)
 
 	dump := cli.NewSubCommand("dump", "Get dump of all secrets").Action(dumpCmd)
@@ -47,17 +59,61 @@ func main() {
 	save.StringFlag("f", "Input file to save secrets from", &SecretsPath)
 	applyCommonFlags(save)
 
-	if err := cli.Run(); err != nil {
-		log.Fatal(err)
+	reencrypt := cli.NewSubCommand("reencrypt", "Re-encrypt secrets of all apps with another encryptor").Action(reencryptCmd)
+	reencrypt.StringFlag("from", "Encryptor the secrets are currently encrypted with", &ReencryptFrom)
+	reencrypt.StringFlag("to", "Encryptor to re-encrypt the secrets with", &ReencryptTo)
+	reencrypt.BoolFlag("dry-run", "Only report what would be re-encrypted", &ReencryptDryRun)
+	reencrypt.IntFlag("batch", "Maximum number of apps to re-encrypt in this run, 0 for all", &ReencryptBatch)
+	reencrypt.StringFlag("state", "File to track re-encrypted apps to resume from", &ReencryptStatePath)
+	reencrypt.StringFlag("d", "Set deployment id", &conf.DeploymentID)
+
+	verify := cli.NewSubCommand("verify", "Verify integrity of the secrets of all apps").Action(verifyCmd)
+	verify.StringFlag("s", "Set scopes", &conf.Scopes)
+	verify.StringFlag("d", "Set deployment id", &conf.DeploymentID)
+
+	encrypt := cli.NewSubCommand("encrypt", "Encrypt values from arguments or stdin as stored by kaigara")
+	encrypt.Action(func() error { return encryptCmd(encrypt.OtherArgs()) })
+	applyCryptFlags(encrypt)
+
+	decrypt := cli.NewSubCommand("decrypt", "Decrypt values from arguments or stdin as read by kaigara")
+	decrypt.Action(func() error { return decryptCmd(decrypt.OtherArgs()) })
+	applyCryptFlags(decrypt)
+
+	err = cli.Run()
+	closeEncryptors()
+	if err != nil {
+		logger.Fatal(err.Error())
+		os.Exit(1)
+	}
+}
+
+func closeEncryptors() {
+	for _, encryptor := range encryptors {
+		if err := storage.CloseEncryptor(encryptor); err != nil {
+			logger.Warn("failed to close encryptor", logger.Err(err))
+		}
 	}
 }
 
 func loadStorageService() (types.Storage, error) {
-	if ss, err := storage.GetStorageService(conf); err != nil {
+	// The deployment id is known once the flags are parsed
+	logger.SetDefault(kaiLogger.With(logger.F("deployment_id", conf.DeploymentID)))
+
+	return loadStorageServiceWith(conf.EncryptMethod)
+}
+
+// loadStorageServiceWith returns the storage encrypting with method, it also decrypts the values of the fallback methods
+func loadStorageServiceWith(method string, fallbacks ...string) (types.Storage, error) {
+	c := *conf
+	c.EncryptMethod = method
+
+	encryptor, err := storage.NewEncryptor(&c, fallbacks...)
+	if err != nil {
 		return nil, err
-	} else {
-		return ss, nil
 	}
+	encryptors = append(encryptors, encryptor)
+
+	return storage.NewStorageService(&c, encryptor)
 }
 
 func applyCommonFlags(cmd *kli.Command) {
@@ -65,3 +121,9 @@ func applyCommonFlags(cmd *kli.Command) {
 	cmd.StringFlag("s", "Set scopes", &conf.Scopes)
 	cmd.StringFlag("d", "Set deployment id", &conf.DeploymentID)
 }
+
+func applyCryptFlags(cmd *kli.Command) {
+	cmd.StringFlag("a", "Set app name", &conf.AppNames)
+	cmd.StringFlag("d", "Set deployment id", &conf.DeploymentID)
+	cmd.StringFlag("e", "Set encryptor", &conf.EncryptMethod)
+}
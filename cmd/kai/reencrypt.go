@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	enc "github.com/openware/kaigara/pkg/encryptor/types"
+	"github.com/openware/kaigara/pkg/logger"
+	"github.com/openware/kaigara/types"
+)
+
+var (
+	ReencryptFrom      string
+	ReencryptTo        string
+	ReencryptDryRun    bool
+	ReencryptBatch     int
+	ReencryptStatePath = "reencrypt.state"
+)
+
+func reencryptCmd() error {
+	if ReencryptFrom == "" || ReencryptTo == "" {
+		return fmt.Errorf("both -from and -to encryptors must be set")
+	}
+
+	if ReencryptFrom == ReencryptTo {
+		return fmt.Errorf("source and target encryptors are the same: %s", ReencryptFrom)
+	}
+
+	// The scopes of an app already saved with the target encryptor by an interrupted run are read again
+	src, err := loadStorageServiceWith(ReencryptFrom, ReencryptTo)
+	if err != nil {
+		return fmt.Errorf("source storage service init failed: %s", err)
+	}
+
+	dst, err := loadStorageServiceWith(ReencryptTo)
+	if err != nil {
+		return fmt.Errorf("target storage service init failed: %s", err)
+	}
+
+	apps, err := src.ListAppNames()
+	if err != nil {
+		return err
+	}
+
+	done, err := readReencryptState(ReencryptStatePath, ReencryptFrom, ReencryptTo)
+	if err != nil {
+		return err
+	}
+
+	pending := []string{}
+	for _, app := range apps {
+		if !done[app] {
+			pending = append(pending, app)
+		}
+	}
+
+	remaining := 0
+	if ReencryptBatch > 0 && len(pending) > ReencryptBatch {
+		remaining = len(pending) - ReencryptBatch
+		pending = pending[:ReencryptBatch]
+	}
+
+	if ReencryptDryRun {
+		return kaireencryptDryRun(src, pending, ReencryptFrom, ReencryptTo, os.Stdout)
+	}
+
+	state, err := os.OpenFile(ReencryptStatePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer state.Close()
+
+	if err := kaireencryptRun(src, dst, pending, ReencryptFrom, ReencryptTo, state); err != nil {
+		return err
+	}
+
+	if remaining > 0 {
+		logger.Info("apps left to re-encrypt, run the command again to continue", logger.F("remaining", remaining))
+		return nil
+	}
+
+	logger.Info("all apps are re-encrypted", logger.F("from", ReencryptFrom), logger.F("to", ReencryptTo))
+	state.Close()
+	return os.Remove(ReencryptStatePath)
+}
+
+// kaireencryptRun decrypts encrypted scopes of the apps with src and saves them with dst.
+// Each migrated app is recorded into state, so the migration can be resumed.
+func kaireencryptRun(src, dst types.Storage, apps []string, from, to string, state io.Writer) error {
+	for _, app := range apps {
+		count := 0
+		for _, scope := range encryptedScopes() {
+			if err := src.Read(app, scope); err != nil {
+				return err
+			}
+
+			entries, err := src.GetEntries(app, scope)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt %s.%s: %s", app, scope, err)
+			}
+			delete(entries, "version")
+
+			if len(entries) == 0 {
+				continue
+			}
+
+			if err := dst.Read(app, scope); err != nil {
+				return err
+			}
+
+			if err := dst.SetEntries(app, scope, entries); err != nil {
+				return fmt.Errorf("failed to encrypt %s.%s: %s", app, scope, err)
+			}
+
+			if err := dst.Write(app, scope); err != nil {
+				return err
+			}
+			count += len(entries)
+		}
+
+		if _, err := fmt.Fprintf(state, "%s,%s,%s\n", from, to, app); err != nil {
+			return err
+		}
+		logger.Info("values re-encrypted", logger.F("app", app), logger.F("count", count))
+	}
+
+	return nil
+}
+
+// kaireencryptDryRun reports how many values of each app would be re-encrypted without writing anything
+func kaireencryptDryRun(src types.Storage, apps []string, from, to string, out io.Writer) error {
+	total := 0
+	for _, app := range apps {
+		for _, scope := range encryptedScopes() {
+			if err := src.Read(app, scope); err != nil {
+				return err
+			}
+
+			entries, err := src.ListEntries(app, scope)
+			if err != nil {
+				return err
+			}
+
+			count, failed := 0, []string{}
+			for _, entry := range entries {
+				if entry == "version" {
+					continue
+				}
+
+				if _, err := src.GetEntry(app, scope, entry); err != nil {
+					failed = append(failed, entry)
+					continue
+				}
+				count++
+			}
+
+			fmt.Fprintf(out, "%s.%s: %d values to re-encrypt from %s to %s\n", app, scope, count, from, to)
+			if len(failed) > 0 {
+				fmt.Fprintf(out, "%s.%s: failed to decrypt with %s: %s\n", app, scope, from, strings.Join(failed, ", "))
+			}
+			total += count
+		}
+	}
+
+	fmt.Fprintf(out, "total: %d values in %d apps\n", total, len(apps))
+	return nil
+}
+
+// encryptedScopes lists the scopes which values are encrypted by the storage drivers
+func encryptedScopes() []string {
+	return enc.NewScopePolicy(conf.EncryptedScopes).Scopes()
+}
+
+// readReencryptState returns apps already migrated from one encryptor to another, as recorded in the state file
+func readReencryptState(path, from, to string) (map[string]bool, error) {
+	done := make(map[string]bool)
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return done, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) == 3 && fields[0] == from && fields[1] == to {
+			done[fields[2]] = true
+		}
+	}
+
+	return done, scanner.Err()
+}
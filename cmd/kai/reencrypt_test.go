@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/encryptor/aes"
+	"github.com/openware/kaigara/pkg/encryptor/plaintext"
+	"github.com/openware/kaigara/pkg/k8s"
+)
+
+func TestKaireencryptRun(t *testing.T) {
+	client := k8s.NewMockClient()
+
+	aesEncrypt, err := aes.NewAESEncryptor([]byte("1234567890123456"))
+	assert.NoError(t, err)
+
+	src, err := k8s.NewService("odax", client, plaintext.NewPlaintextEncryptor())
+	assert.NoError(t, err)
+
+	dst, err := k8s.NewService("odax", client, aesEncrypt)
+	assert.NoError(t, err)
+
+	assert.NoError(t, src.Read("finex", "secret"))
+	assert.NoError(t, src.SetEntry("finex", "secret", "finex_database_password", "changeme"))
+	assert.NoError(t, src.Write("finex", "secret"))
+
+	var report bytes.Buffer
+	assert.NoError(t, kaireencryptDryRun(src, []string{"finex"}, "plaintext", "aes", &report))
+	assert.Contains(t, report.String(), "finex.secret: 1 values to re-encrypt from plaintext to aes")
+
+	var state bytes.Buffer
+	assert.NoError(t, kaireencryptRun(src, dst, []string{"finex"}, "plaintext", "aes", &state))
+	assert.Equal(t, "plaintext,aes,finex\n", state.String())
+
+	raw, err := client.ReadSecret("kaigara-finex", "odax")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(raw["finex_database_password"]), "kgr:v1:aes:"))
+
+	assert.NoError(t, dst.Read("finex", "secret"))
+	value, err := dst.GetEntry("finex", "secret", "finex_database_password")
+	assert.NoError(t, err)
+	assert.Equal(t, "changeme", value)
+}
+
+func TestReadReencryptState(t *testing.T) {
+	statePath := path.Join(t.TempDir(), "reencrypt.state")
+
+	done, err := readReencryptState(statePath, "plaintext", "aes")
+	assert.NoError(t, err)
+	assert.Empty(t, done)
+
+	err = os.WriteFile(statePath, []byte("plaintext,aes,finex\naes,transit,peatio\nplaintext,aes,barong\n"), 0644)
+	assert.NoError(t, err)
+
+	done, err = readReencryptState(statePath, "plaintext", "aes")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"finex": true, "barong": true}, done)
+}
@@ -2,10 +2,11 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"os"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/openware/kaigara/pkg/logger"
 )
 
 // App contains a map of scopes(public, private, secret) with secrets to be loaded
@@ -42,7 +43,7 @@ func saveCmd() error {
 
 			delete(data, "version")
 			for k, v := range data {
-				log.Printf("INF: setting %s.%s.%s", app, scope, k)
+				logger.Info("setting entry", logger.F("app", app), logger.F("scope", scope), logger.F("entry", k))
 				if err := ss.SetEntry(app, scope, k, v); err != nil {
 					return err
 				}
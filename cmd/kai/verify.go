@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/openware/kaigara/pkg/storage"
+)
+
+func verifyCmd() error {
+	ss, err := loadStorageService()
+	if err != nil {
+		return fmt.Errorf("storage service init failed: %s", err)
+	}
+
+	is, ok := ss.(*storage.IntegrityStorage)
+	if !ok {
+		return fmt.Errorf("integrity is not configured, set KAIGARA_INTEGRITY_KEY or KAIGARA_INTEGRITY_KEY_FILE")
+	}
+
+	apps, err := is.ListAppNames()
+	if err != nil {
+		return err
+	}
+
+	return kaiverifyRun(is, apps, strings.Split(conf.Scopes, ","), os.Stdout)
+}
+
+// kaiverifyRun checks the integrity record of every scope of the apps and reports the result of each one
+func kaiverifyRun(is *storage.IntegrityStorage, apps, scopes []string, out io.Writer) error {
+	failed := 0
+	for _, app := range apps {
+		for _, scope := range scopes {
+			// Read through the wrapped storage, so that enforce mode doesn't stop the audit
+			if err := is.Storage.Read(app, scope); err != nil {
+				return err
+			}
+
+			err := is.Verify(app, scope)
+			switch {
+			case err == nil:
+				signed, err := isSigned(is, app, scope)
+				if err != nil {
+					return err
+				}
+				// The record of an empty document is only missing when it was never signed, or deleted with it
+				if !signed {
+					fmt.Fprintf(out, "%s.%s: empty, unsigned\n", app, scope)
+					continue
+				}
+				fmt.Fprintf(out, "%s.%s: ok\n", app, scope)
+				continue
+			case errors.Is(err, storage.ErrIntegrityMissing):
+				fmt.Fprintf(out, "%s.%s: unsigned\n", app, scope)
+			case errors.Is(err, storage.ErrIntegrityMismatch):
+				fmt.Fprintf(out, "%s.%s: tampered\n", app, scope)
+			default:
+				fmt.Fprintf(out, "%s.%s: failed: %s\n", app, scope, err)
+			}
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("integrity check failed for %d scopes", failed)
+	}
+
+	return nil
+}
+
+// isSigned tells if the document loaded by the wrapped storage holds an integrity record
+func isSigned(is *storage.IntegrityStorage, app, scope string) (bool, error) {
+	raw, err := is.Storage.GetEntries(app, scope)
+	if err != nil {
+		return false, err
+	}
+	_, ok := raw[storage.IntegrityKey]
+
+	return ok, nil
+}
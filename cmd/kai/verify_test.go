@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/k8s"
+	"github.com/openware/kaigara/pkg/storage"
+)
+
+func TestKaiverifyRun(t *testing.T) {
+	client := k8s.NewMockClient()
+
+	ss := k8s.NewMockService(client)
+	is := storage.NewIntegrityStorage(ss, []byte("0123456789abcdef0123456789abcdef"), true, true)
+
+	assert.NoError(t, is.Read("finex", "secret"))
+	assert.NoError(t, is.SetEntry("finex", "secret", "finex_database_password", "changeme"))
+	assert.NoError(t, is.Write("finex", "secret"))
+
+	assert.NoError(t, ss.Read("peatio", "secret"))
+	assert.NoError(t, ss.SetEntry("peatio", "secret", "peatio_database_password", "changeme"))
+	assert.NoError(t, ss.Write("peatio", "secret"))
+
+	var report bytes.Buffer
+	err := kaiverifyRun(is, []string{"finex", "peatio", "barong"}, []string{"secret"}, &report)
+	assert.EqualError(t, err, "integrity check failed for 1 scopes")
+	assert.Equal(t, "finex.secret: ok\npeatio.secret: unsigned\nbarong.secret: empty, unsigned\n", report.String())
+
+	writer := k8s.NewMockService(client)
+	assert.NoError(t, writer.Read("finex", "secret"))
+	assert.NoError(t, writer.SetEntry("finex", "secret", "finex_database_password", "hacked"))
+	assert.NoError(t, writer.Write("finex", "secret"))
+
+	report.Reset()
+	err = kaiverifyRun(is, []string{"finex"}, []string{"secret"}, &report)
+	assert.Error(t, err)
+	assert.Equal(t, "finex.secret: tampered\n", report.String())
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// restarter delays the restarts of the command exponentially and stops crash loops
+type restarter struct {
+	backoff    time.Duration
+	maxBackoff time.Duration
+	limit      int
+	window     time.Duration
+	restarts   []time.Time
+}
+
+func newRestarter(backoff, maxBackoff time.Duration, limit int, window time.Duration) *restarter {
+	return &restarter{
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+		limit:      limit,
+		window:     window,
+	}
+}
+
+// next records a restart at now and returns how long to wait before it.
+// The first restart within the window is immediate, the next ones wait for the backoff doubled each time up to its ceiling.
+// An error is returned once the command was restarted more than the limit within the window, a zero limit disables it.
+func (r *restarter) next(now time.Time) (time.Duration, error) {
+	recent := r.restarts[:0]
+	for _, t := range r.restarts {
+		if now.Sub(t) < r.window {
+			recent = append(recent, t)
+		}
+	}
+	r.restarts = append(recent, now)
+
+	if r.limit > 0 && len(recent) >= r.limit {
+		return 0, fmt.Errorf("command was restarted %d times within %s", len(recent), r.window)
+	}
+	if len(recent) == 0 {
+		return 0, nil
+	}
+
+	delay := r.backoff
+	for i := 1; i < len(recent) && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.maxBackoff {
+		delay = r.maxBackoff
+	}
+
+	return delay, nil
+}
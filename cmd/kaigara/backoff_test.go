@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestarterBackoff(t *testing.T) {
+	r := newRestarter(time.Second, 5*time.Second, 0, time.Minute)
+	now := time.Now()
+
+	for _, expected := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		delay, err := r.next(now)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, delay)
+		now = now.Add(time.Second)
+	}
+
+	// Restarts out of the window are forgotten
+	delay, err := r.next(now.Add(2 * time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), delay)
+}
+
+func TestRestarterLimit(t *testing.T) {
+	r := newRestarter(time.Second, 5*time.Second, 3, time.Minute)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		_, err := r.next(now.Add(time.Duration(i) * time.Second))
+		assert.NoError(t, err)
+	}
+
+	_, err := r.next(now.Add(3 * time.Second))
+	assert.Error(t, err)
+
+	_, err = r.next(now.Add(2 * time.Minute))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/openware/kaigara/pkg/logger"
+	"github.com/openware/kaigara/pkg/storage"
+	"github.com/openware/kaigara/types"
+)
+
+// execFlag enables the exec mode from the command line, like KAIGARA_EXEC=true
+const execFlag = "--exec"
+
+// parseExecFlag enables the exec mode if the arguments start with --exec and returns the arguments without it
+func parseExecFlag(args []string) []string {
+	if len(args) > 0 && args[0] == execFlag {
+		conf.Exec = true
+		return args[1:]
+	}
+
+	return args
+}
+
+// kaigaraExec replaces kaigara with the command, started with the environment and files built from the storage,
+// so it keeps the PID, the signals and the exit code of kaigara. It only returns on error.
+func kaigaraExec(ss types.Storage, procs []*process) error {
+	if conf.Procfile != "" {
+		return fmt.Errorf("exec mode can't run the processes of a Procfile")
+	}
+	if conf.KfileCleanup {
+		logger.Warn("files aren't removed in exec mode, kaigara doesn't outlive the command")
+	}
+	p := procs[0]
+
+	envs, err := loadEnv(ss)
+	if err != nil {
+		return fmt.Errorf("failed to build environment: %s", err)
+	}
+	if err := writeFiles(envs.Files); err != nil {
+		return err
+	}
+
+	path, err := exec.LookPath(p.cmd)
+	if err != nil {
+		return fmt.Errorf("failed to find command: %s", err)
+	}
+
+	// The encryptor isn't needed by the command, the process of a persistent exec encryptor is stopped
+	if err := storage.CloseEncryptor(encryptor); err != nil {
+		logger.Warn("failed to close encryptor", logger.Err(err))
+	}
+
+	logger.Info("replacing kaigara with command", logger.F("cmd", p.cmd), logger.F("args", p.args))
+	if err := execve(path, append([]string{p.cmd}, p.args...), envs.Vars); err != nil {
+		return fmt.Errorf("failed to exec command: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+
+package main
+
+import (
+	"encoding/base64"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExecFlag(t *testing.T) {
+	defer func() { conf.Exec = false }()
+
+	assert.Equal(t, []string{"rake", "db:migrate"}, parseExecFlag([]string{"rake", "db:migrate"}))
+	assert.False(t, conf.Exec)
+
+	assert.Equal(t, []string{"rake", "--exec"}, parseExecFlag([]string{"--exec", "rake", "--exec"}))
+	assert.True(t, conf.Exec)
+}
+
+func TestKaigaraExec(t *testing.T) {
+	defer removeFiles()
+
+	config := path.Join(t.TempDir(), "config.yml")
+	ss, _ := newReloadStorage(t, map[string]interface{}{
+		"finex_database_host":  "db",
+		"kfile_config_path":    config,
+		"kfile_config_content": base64.StdEncoding.EncodeToString([]byte("app: finex")),
+	})
+
+	var execPath string
+	var execArgs, execEnv []string
+	defer func(prev func(string, []string, []string) error) { execve = prev }(execve)
+	execve = func(path string, args []string, env []string) error {
+		execPath, execArgs, execEnv = path, args, env
+		return nil
+	}
+
+	assert.NoError(t, kaigaraExec(ss, []*process{newProcess("", "go", []string{"version"})}))
+	assert.Contains(t, execPath, "go")
+	assert.Equal(t, []string{"go", "version"}, execArgs)
+	assert.Contains(t, execEnv, "FINEX_DATABASE_HOST=db")
+	assert.FileExists(t, config)
+
+	err := kaigaraExec(ss, []*process{newProcess("", "kaigara-missing-command", nil)})
+	assert.ErrorContains(t, err, "failed to find command")
+}
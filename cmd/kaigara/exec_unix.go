@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// execve replaces kaigara with the program at path
+var execve = syscall.Exec
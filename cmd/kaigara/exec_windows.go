@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "fmt"
+
+// execve is not supported on Windows, which can't replace a process with another one
+var execve = func(path string, args []string, env []string) error {
+	return fmt.Errorf("exec mode is not supported on Windows")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/openware/kaigara/pkg/config"
+	"github.com/openware/kaigara/pkg/env"
+	"github.com/openware/kaigara/pkg/logger"
+)
+
+// written holds the files last written by name, so only changed files are rewritten and they can be removed on exit
+var written = map[string]*config.File{}
+
+// writeFiles writes the KFILE and KTPL files which changed since they were last written
+func writeFiles(files map[string]*config.File) error {
+	for name, file := range files {
+		if prev, ok := written[name]; ok && *prev == *file {
+			continue
+		}
+
+		if err := env.WriteFile(file); err != nil {
+			return fmt.Errorf("failed to write file %s: %s", file.Path, err)
+		}
+		logger.Info("wrote file", logger.F("path", file.Path))
+
+		// With cleanup, a file moved to another path isn't left behind
+		if prev, ok := written[name]; ok && prev.Path != file.Path && conf.KfileCleanup {
+			removeFile(prev.Path)
+		}
+		written[name] = file
+	}
+
+	return nil
+}
+
+// removeFiles removes the files written by kaigara
+func removeFiles() {
+	for name, file := range written {
+		removeFile(file.Path)
+		delete(written, name)
+	}
+}
+
+func removeFile(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logger.Warn("failed to remove file", logger.F("path", path), logger.Err(err))
+		return
+	}
+	logger.Info("removed file", logger.F("path", path))
+}
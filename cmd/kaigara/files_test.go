@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/config"
+)
+
+func TestWriteFiles(t *testing.T) {
+	defer removeFiles()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yml")
+	files := map[string]*config.File{
+		"CONFIG": {Path: path, Content: "app: finex", Encoding: "raw"},
+	}
+
+	assert.NoError(t, writeFiles(files))
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "app: finex", string(content))
+
+	// An unchanged file isn't rewritten
+	assert.NoError(t, os.WriteFile(path, []byte("edited"), 0600))
+	assert.NoError(t, writeFiles(map[string]*config.File{
+		"CONFIG": {Path: path, Content: "app: finex", Encoding: "raw"},
+	}))
+	content, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "edited", string(content))
+
+	assert.Error(t, writeFiles(map[string]*config.File{
+		"CONFIG": {Path: path, Content: "app: finex"},
+	}))
+
+	// With cleanup, a moved file is removed from its previous path
+	conf.KfileCleanup = true
+	defer func() { conf.KfileCleanup = false }()
+
+	moved := filepath.Join(dir, "config", "app.yml")
+	assert.NoError(t, writeFiles(map[string]*config.File{
+		"CONFIG": {Path: moved, Content: "app: finex", Encoding: "raw"},
+	}))
+	assert.NoFileExists(t, path)
+	assert.FileExists(t, moved)
+
+	removeFiles()
+	assert.NoFileExists(t, moved)
+	assert.Empty(t, written)
+}
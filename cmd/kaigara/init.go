@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os/exec"
+	"sync"
+)
+
+// managed holds the pids of the running processes, they're reaped by their exec.Cmd and not by the init reaper
+var managed = struct {
+	sync.Mutex
+	pids map[int]bool
+}{pids: map[int]bool{}}
+
+// startManaged starts the command and records its pid under the lock of the reaper, so a process exiting right away
+// isn't reaped as an orphan before its exec.Cmd waits for it
+func startManaged(c *exec.Cmd) error {
+	managed.Lock()
+	defer managed.Unlock()
+
+	if err := c.Start(); err != nil {
+		return err
+	}
+	managed.pids[c.Process.Pid] = true
+
+	return nil
+}
+
+// releaseManaged forgets the pid of a process once its exec.Cmd waited for it
+func releaseManaged(pid int) {
+	managed.Lock()
+	defer managed.Unlock()
+
+	delete(managed.pids, pid)
+}
+
+// groupProcesses tells if the processes are started in their own process group and signaled as a group
+func groupProcesses() bool {
+	return conf.SignalProcessGroup || conf.Init
+}
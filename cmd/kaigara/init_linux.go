@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/openware/kaigara/pkg/logger"
+)
+
+// reapInterval is the interval at which zombies are reaped besides SIGCHLD, a zombie can be skipped when its SIGCHLD
+// arrives while a process started by kaigara is waiting to be reaped by its exec.Cmd
+const reapInterval = time.Second
+
+// startInit sets kaigara up as the init of the container: it becomes a child subreaper if configured and not PID 1,
+// and reaps the orphaned processes in background
+func startInit() error {
+	if os.Getpid() == 1 {
+		logger.Info("running as PID 1, reaping orphaned processes")
+	} else if conf.Subreaper {
+		if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0); err != nil {
+			return fmt.Errorf("failed to become a child subreaper: %s", err)
+		}
+		logger.Info("running as child subreaper, reaping orphaned processes")
+	} else {
+		logger.Warn("not running as PID 1, orphaned processes are only reaped with KAIGARA_SUBREAPER=true")
+	}
+
+	sigchld := make(chan os.Signal, 1)
+	signal.Notify(sigchld, syscall.SIGCHLD)
+
+	go func() {
+		ticker := time.NewTicker(reapInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-sigchld:
+			case <-ticker.C:
+			}
+			reapZombies()
+		}
+	}()
+
+	return nil
+}
+
+// reapZombies waits for the zombie children of kaigara which aren't waited for by an exec.Cmd: the running processes
+// are left to their exec.Cmd, and so are the commands run in the process group of kaigara, like reload commands
+func reapZombies() {
+	pgrp := syscall.Getpgrp()
+
+	for _, z := range zombieChildren() {
+		if z.pgrp != pgrp {
+			reapOrphan(z.pid)
+		}
+	}
+}
+
+// reapOrphan waits for the zombie unless it's a process started by kaigara, checked under the lock of startManaged
+// since the process may have been started and exited after the zombies were listed
+func reapOrphan(pid int) {
+	managed.Lock()
+	defer managed.Unlock()
+
+	if managed.pids[pid] {
+		return
+	}
+
+	var status syscall.WaitStatus
+	if _, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err != nil && err != syscall.ECHILD {
+		logger.Warn("failed to reap process", logger.F("pid", pid), logger.Err(err))
+	}
+}
+
+// procStat holds the fields of /proc/<pid>/stat used to find zombies
+type procStat struct {
+	pid   int
+	state string
+	ppid  int
+	pgrp  int
+}
+
+// zombieChildren returns the zombie children of kaigara, read from /proc
+func zombieChildren() []procStat {
+	paths, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return nil
+	}
+
+	self := os.Getpid()
+	zombies := []procStat{}
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		stat, err := parseProcStat(string(raw))
+		if err != nil {
+			continue
+		}
+		if stat.state == "Z" && stat.ppid == self {
+			zombies = append(zombies, stat)
+		}
+	}
+
+	return zombies
+}
+
+// parseProcStat parses a /proc/<pid>/stat line like "42 (sh) Z 1 42 ...", the command name can hold spaces and parentheses
+func parseProcStat(line string) (procStat, error) {
+	open, end := strings.IndexByte(line, '('), strings.LastIndexByte(line, ')')
+	if open < 0 || end < open {
+		return procStat{}, fmt.Errorf("invalid stat line: %s", line)
+	}
+
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 3 {
+		return procStat{}, fmt.Errorf("invalid stat line: %s", line)
+	}
+
+	var stat procStat
+	var err error
+	if stat.pid, err = strconv.Atoi(strings.TrimSpace(line[:open])); err != nil {
+		return procStat{}, err
+	}
+	stat.state = fields[0]
+	if stat.ppid, err = strconv.Atoi(fields[1]); err != nil {
+		return procStat{}, err
+	}
+	if stat.pgrp, err = strconv.Atoi(fields[2]); err != nil {
+		return procStat{}, err
+	}
+
+	return stat, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestParseProcStat(t *testing.T) {
+	stat, err := parseProcStat("42 (my (weird) app) Z 1 40 40 0 -1 4194560")
+	assert.NoError(t, err)
+	assert.Equal(t, procStat{pid: 42, state: "Z", ppid: 1, pgrp: 40}, stat)
+
+	_, err = parseProcStat("42 sh Z 1 40")
+	assert.Error(t, err)
+}
+
+// orphanPID returns the pid written to the file by the shell of the test
+func orphanPID(t *testing.T, file string) int {
+	raw, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	assert.NoError(t, err)
+
+	return pid
+}
+
+func TestReapZombies(t *testing.T) {
+	// Orphans of the test process are reparented to it instead of the init of the system
+	assert.NoError(t, unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0))
+	defer unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 0, 0, 0, 0)
+
+	file := path.Join(t.TempDir(), "pid")
+	c := exec.Command("sh", "-c", "sleep 0.1 & echo $! > "+file)
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	assert.NoError(t, c.Run())
+	pid := orphanPID(t, file)
+
+	time.Sleep(300 * time.Millisecond)
+	assert.Contains(t, zombiePIDs(), pid)
+
+	reapZombies()
+	assert.NotContains(t, zombiePIDs(), pid)
+}
+
+func TestReapZombiesSkipsManaged(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				reapZombies()
+			}
+		}
+	}()
+
+	// The processes exit right away, they're left to their exec.Cmd even when the reaper sees them first
+	for i := 0; i < 20; i++ {
+		c := exec.Command("true")
+		c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		assert.NoError(t, startManaged(c))
+		time.Sleep(5 * time.Millisecond)
+		assert.NoError(t, c.Wait())
+		releaseManaged(c.Process.Pid)
+	}
+}
+
+func TestKaigaraRunInitKillsGroup(t *testing.T) {
+	conf.Init = true
+	defer func() { conf.Init = false }()
+
+	file := path.Join(t.TempDir(), "pid")
+	ss := newMockStorage(t)
+	restart, code := runCmd(t, ss, "sh", "-c", "sleep 10 & echo $! > "+file)
+	assert.False(t, restart)
+	assert.Equal(t, 0, code)
+
+	// The worker left by the command was killed once it exited
+	pid := orphanPID(t, file)
+	assert.Eventually(t, func() bool {
+		stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+		return err != nil || strings.Contains(string(stat), ") Z ")
+	}, time.Second, 10*time.Millisecond)
+}
+
+func zombiePIDs() []int {
+	pids := []int{}
+	for _, z := range zombieChildren() {
+		pids = append(pids, z.pid)
+	}
+
+	return pids
+}
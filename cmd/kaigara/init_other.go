@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// startInit is only supported on Linux, which container images run on
+func startInit() error {
+	return fmt.Errorf("init mode is only supported on Linux")
+}
@@ -1,24 +1,34 @@
 package main
 
 import (
-	"encoding/base64"
-	"log"
+	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
-	"path"
+	"os/signal"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/openware/kaigara/pkg/config"
+	enc "github.com/openware/kaigara/pkg/encryptor/types"
 	"github.com/openware/kaigara/pkg/env"
+	"github.com/openware/kaigara/pkg/logger"
+	"github.com/openware/kaigara/pkg/metrics"
 	"github.com/openware/kaigara/pkg/storage"
 	"github.com/openware/kaigara/types"
 )
 
 var (
-	conf    *config.KaigaraConfig
-	restart chan int
-	Version = "master"
+	conf          *config.KaigaraConfig
+	restartSignal os.Signal
+	received      chan os.Signal
+	onChange      = &changeAction{mode: onChangeRestart}
+	pollInterval  = time.Second * 5
+	rules         *env.Rules
+	encryptor     enc.Encryptor
+	Version       = "master"
 )
 
 func parseScopes() []string {
@@ -29,108 +39,512 @@ func parseAppNames() []string {
 	return strings.Split((*conf).AppNames, ",")
 }
 
-func kaigaraRun(ss types.Storage, cmd string, cmdArgs []string) {
+// stopReason tells why kaigaraRun returned
+type stopReason int
+
+const (
+	// processExited is returned when the command exited by itself
+	processExited stopReason = iota
+	// secretsUpdated is returned when the command was stopped to be restarted with updated secrets
+	secretsUpdated
+	// shutdownRequested is returned when the command exited after kaigara received a shutdown signal
+	shutdownRequested
+)
+
+// kaigaraRun runs the processes with the environment and files of envs until one of them exits or the secrets are updated,
+// it returns why it stopped and the exit code of the processes.
+// envs is updated when the processes are reloaded.
+func kaigaraRun(ss types.Storage, envs *config.Env, procs []*process) (stopReason, int, error) {
 	scopes := parseScopes()
-	c := exec.Command(cmd, cmdArgs...)
-	envs, err := env.BuildCmdEnv(parseAppNames(), ss, os.Environ(), scopes)
-	if err != nil {
-		panic(err)
+	// With cleanup, the files are removed once the processes stopped, they're written again on restart
+	if conf.KfileCleanup {
+		defer removeFiles()
+	}
+	if err := writeFiles(envs.Files); err != nil {
+		return processExited, 0, err
+	}
+
+	exits := make(chan *process, len(procs))
+	for _, p := range procs {
+		if err := p.start(envs.Vars, exits); err != nil {
+			stopProcesses(procs, restartSignal, conf.RestartGracePeriod)
+			return processExited, 0, err
+		}
+	}
+
+	// The watcher is stopped and waited for on return, so it doesn't outlive the processes
+	outdated := make(chan outdatedEvent, 1)
+	stop := make(chan struct{})
+	var watcher sync.WaitGroup
+	watch := func() {
+		offline := fromSnapshot
+		watcher.Add(1)
+		go func() {
+			defer watcher.Done()
+			if offline {
+				notifyWhenStorageReachable(ss, outdated, stop)
+				return
+			}
+			notifyWhenSecretsOutdated(ss, scopes, outdated, stop)
+		}()
 	}
+	// retry sends the event again on the next poll, the versions already read wouldn't be reported as outdated
+	retry := func(event outdatedEvent) {
+		watcher.Add(1)
+		go func() {
+			defer watcher.Done()
+			select {
+			case <-stop:
+			case <-time.After(pollInterval):
+				outdated <- event
+			}
+		}()
+	}
+	defer watcher.Wait()
+	defer close(stop)
+	watch()
+
+	restarting := make(chan *process, len(procs))
+	shutdown := false
+	code := 0
+	for {
+		select {
+		case p := <-exits:
+			p.running = false
+			exited := exitCode(p.c.ProcessState)
 
-	c.Env = envs.Vars
-	c.Stdout = os.Stdout
-	c.Stdin = os.Stdin
-	c.Stderr = os.Stderr
+			if shutdown {
+				if code == 0 {
+					code = exited
+				}
+				if !anyRunning(procs) {
+					return shutdownRequested, code, nil
+				}
+				continue
+			}
+
+			if len(procs) == 1 {
+				return processExited, exited, nil
+			}
+
+			if conf.ProcfileOnExit == procfileRestart {
+				delay, err := p.restarts.next(time.Now())
+				if err == nil {
+					logRestart("exited", p.logFields(logger.F("code", exited), logger.F("delay", delay))...)
+					time.AfterFunc(delay, func() { restarting <- p })
+					continue
+				}
+				logger.Error("process restarted too often", p.logFields(logger.Err(err))...)
+			}
+
+			logger.Info("process exited, stopping all processes", p.logFields(logger.F("code", exited))...)
+			stopProcesses(procs, restartSignal, conf.RestartGracePeriod)
+			if shutdownReceived() {
+				return shutdownRequested, exited, nil
+			}
+			return processExited, exited, nil
+
+		case p := <-restarting:
+			if shutdown || p.running {
+				continue
+			}
+			if err := p.start(envs.Vars, exits); err != nil {
+				stopProcesses(procs, restartSignal, conf.RestartGracePeriod)
+				return processExited, 0, err
+			}
+
+		case sig := <-received:
+			if !isForwarded(sig) {
+				continue
+			}
+			if isShutdownSignal(sig) {
+				logger.Info("received shutdown signal, forwarding it to process", logger.F("signal", signalName(sig)))
+				shutdown = true
+			}
+			for _, p := range procs {
+				if !p.running {
+					continue
+				}
+				if err := signalCmd(p.c, sig); err != nil {
+					logger.Warn("failed to forward signal", p.logFields(logger.F("signal", signalName(sig)), logger.Err(err))...)
+				}
+			}
+			if shutdown && !anyRunning(procs) {
+				return shutdownRequested, code, nil
+			}
+
+		case event := <-outdated:
+			if shutdown {
+				continue
+			}
 
-	for _, file := range envs.Files {
-		if err := os.MkdirAll(path.Dir(file.Path), 0750); err != nil {
-			log.Printf("ERR: failed to make dir %s: %s", file.Path, err.Error())
+			logger.Info(event.msg, event.fields...)
+			next, err := liveEnv(ss)
+			if err != nil {
+				logger.Warn("failed to build environment, keeping process running until the next poll", logger.Err(err))
+				retry(event)
+				continue
+			}
+
+			vars, files := env.Diff(envs, next)
+			if len(vars) == 0 && len(files) == 0 {
+				logger.Info("environment is unchanged, keeping process running")
+				watch()
+				continue
+			}
+			if len(vars) > 0 {
+				logger.Info("environment variables changed", logger.F("vars", vars))
+			}
+			if len(files) > 0 {
+				logger.Info("files changed", logger.F("files", files))
+			}
+
+			if onChange.mode != onChangeRestart {
+				err = reloadProcesses(procs, next, vars, files)
+				if err == nil {
+					*envs = *next
+					watch()
+					continue
+				}
+				logger.Info("reload failed, falling back to restart", logger.Err(err))
+			}
+
+			logger.Info("stopping process to restart it with the updated secrets")
+			stopProcesses(procs, restartSignal, conf.RestartGracePeriod)
+
+			if shutdownReceived() {
+				return shutdownRequested, stoppedCode(procs), nil
+			}
+			logRestart("secrets_updated", event.fields...)
+			return secretsUpdated, 0, nil
+		}
+	}
+}
+
+// stoppedCode returns the first non-zero exit code of the stopped processes
+func stoppedCode(procs []*process) int {
+	for _, p := range procs {
+		if code := exitCode(p.c.ProcessState); code != 0 {
+			return code
+		}
+	}
+
+	return 0
+}
+
+// anyRunning tells if one of the processes is still running
+func anyRunning(procs []*process) bool {
+	for _, p := range procs {
+		if p.running {
+			return true
 		}
+	}
+
+	return false
+}
+
+// kaigaraSupervise runs the command and restarts it when the secrets are updated or when it exits according to the restart policy,
+// with a backoff, until it exits or kaigara is asked to stop. It returns the exit code of kaigara.
+func kaigaraSupervise(ss types.Storage, procs []*process) int {
+	restarts := newRestarter(conf.RestartBackoff, conf.RestartBackoffMax, conf.RestartLimit, conf.RestartWindow)
+	// good is the last environment the command ran with for the rollback period
+	var envs, good *config.Env
+	rollback := false
+	// exited tells if the command is restarted because it exited, restarts following secret updates
+	// aren't delayed nor counted as crashes
+	exited := false
 
-		contents, err := base64.StdEncoding.DecodeString(file.Content)
+	for {
+		if exited {
+			delay, err := restarts.next(time.Now())
+			if err != nil {
+				logger.Fatal("process restarted too often, exiting", logger.Err(err))
+				return 1
+			}
+			if delay > 0 {
+				logger.Info("waiting before restarting process", logger.F("delay", delay))
+				if !waitRestart(delay) {
+					return 0
+				}
+			}
+		}
+		exited = true
+
+		if rollback {
+			envs = good
+		} else {
+			next, err := loadEnv(ss)
+			if err != nil {
+				logger.Error("failed to build environment", logger.Err(err))
+				if conf.RollbackPeriod == 0 || good == nil {
+					logRestart("environment_error")
+					continue
+				}
+				logger.Warn("starting process with the last known good secrets")
+				next = good
+			}
+			envs = next
+		}
+
+		start := time.Now()
+		reason, code, err := kaigaraRun(ss, envs, procs)
 		if err != nil {
-			log.Printf("ERR: failed to decode string %s: %s", file.Content, err.Error())
+			logger.Fatal(err.Error())
+			return 1
+		}
+
+		ran := time.Since(start)
+		if conf.RollbackPeriod > 0 && ran >= conf.RollbackPeriod {
+			good = envs
+		}
+		switch reason {
+		case secretsUpdated:
+			rollback, exited = false, false
+			continue
+		case shutdownRequested:
+			logger.Info("process exited", logger.F("code", code))
+			return code
 		}
 
-		if err := os.WriteFile(file.Path, contents, 0640); err != nil {
-			log.Printf("ERR: failed to write file %s: %s", file.Path, err.Error())
+		if conf.RollbackPeriod > 0 && ran < conf.RollbackPeriod && good != nil && envs != good {
+			logger.Warn("process exited after starting with the updated secrets, rolling back to the last known good secrets", logger.F("code", code), logger.F("after", ran.Round(time.Millisecond)))
+			logRestart("rollback", logger.F("code", code))
+			rollback = true
+			continue
 		}
+
+		if restartOnExit(conf.RestartPolicy, code) {
+			logRestart("exited", logger.F("code", code), logger.F("policy", conf.RestartPolicy))
+			rollback = false
+			continue
+		}
+
+		logger.Info("process exited", logger.F("code", code))
+		return code
 	}
+}
+
+// waitRestart waits for the delay before a restart, it returns false if a shutdown signal was received meanwhile
+func waitRestart(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
 
-	log.Printf("INF: starting command: %s %v\n", cmd, cmdArgs)
-	if err := c.Start(); err != nil {
-		log.Fatal(err)
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case sig := <-received:
+			if isShutdownSignal(sig) {
+				logger.Info("received shutdown signal while waiting to restart process, exiting", logger.F("signal", signalName(sig)))
+				return false
+			}
+		}
 	}
+}
 
-	go exitWhenSecretsOutdated(c, ss, scopes)
+// buildEnv builds the environment and files of the command from the secret storage
+func buildEnv(ss types.Storage) (*config.Env, error) {
+	return env.BuildCmdEnv(parseAppNames(), ss, os.Environ(), parseScopes(), rules)
+}
+
+// signalCmd sends the signal to the command, or to its process group if configured
+func signalCmd(c *exec.Cmd, sig os.Signal) error {
+	return signalProcess(c, sig, groupProcesses())
+}
 
-	if err := c.Wait(); err != nil {
-		log.Printf("Process completed: %s\n", err)
+// shutdownReceived drains the signals received while the command was stopped and tells if one of them asked to stop
+func shutdownReceived() bool {
+	shutdown := false
+	for {
+		select {
+		case sig := <-received:
+			shutdown = shutdown || isShutdownSignal(sig)
+		default:
+			return shutdown
+		}
 	}
 }
 
-func exitWhenSecretsOutdated(c *exec.Cmd, ss types.Storage, scopes []string) {
+// outdatedEvent tells why the environment of the processes is outdated, with the fields of the log line
+type outdatedEvent struct {
+	msg    string
+	fields []logger.Field
+}
+
+// notifyWhenSecretsOutdated polls the secret storage and sends an event to outdated once a scope has a new version,
+// until stop is closed
+func notifyWhenSecretsOutdated(ss types.Storage, scopes []string, outdated chan<- outdatedEvent, stop <-chan struct{}) {
 	appNames := parseAppNames()
 
 	if ignore, ok := os.LookupEnv("KAIGARA_IGNORE_GLOBAL"); !ok || ignore != "true" {
 		appNames = append(appNames, "global")
 	}
 
-	for range time.Tick(time.Second * 5) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		failed := false
 		for _, appName := range appNames {
 			for _, scope := range scopes {
 				current, err := ss.GetCurrentVersion(appName, scope)
 				if err != nil {
-					log.Println(err.Error())
+					logger.Error("failed to poll secrets version", logger.F("app", appName), logger.F("scope", scope), logger.Err(err))
+					failed = true
 					break
 				}
+				metrics.SetSecretsVersion(appName, scope, current)
+
 				latest, err := ss.GetLatestVersion(appName, scope)
 				if err != nil {
-					log.Println(err.Error())
+					logger.Error("failed to poll secrets version", logger.F("app", appName), logger.F("scope", scope), logger.Err(err))
+					failed = true
 					break
 				}
 				if current != latest {
-					log.Printf("INF: found secrets updated on '%v' scope. from: v%v, to: v%v, restarting process...\n", scope, current, latest)
-					if err := c.Process.Signal(os.Kill); err != nil {
-						log.Printf("FTL: failed to kill process: %s", err.Error())
-					}
-					restart <- 1
+					metrics.PollSucceeded()
+					outdated <- outdatedEvent{msg: "found updated secrets", fields: []logger.Field{
+						logger.F("app", appName), logger.F("scope", scope), logger.F("from_version", current), logger.F("version", latest),
+					}}
 					return
 				}
 			}
 		}
+
+		if failed {
+			metrics.PollFailed()
+		} else {
+			metrics.PollSucceeded()
+		}
 	}
 }
 
-func main() {
-	log.SetPrefix("[Kaigara] ")
-	if len(os.Args) < 2 {
-		panic("Usage: kaigara CMD [ARGS...]")
+// serveMetrics listens on addr and serves the metrics and the health check in background
+func serveMetrics(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for metrics: %s", err)
 	}
 
-	log.Printf("INF: Starting Kaigara version %s\n", Version)
+	logger.Info("serving metrics and health check", logger.F("addr", listener.Addr()))
+	go func() {
+		// The secrets are polled every pollInterval, a few missed polls make kaigara unhealthy
+		if err := http.Serve(listener, metrics.Handler(6*pollInterval)); err != nil {
+			logger.Error("metrics listener failed", logger.Err(err))
+		}
+	}()
+
+	return nil
+}
 
+// fatal logs the error and exits, it replaces panic once the logger is configured
+func fatal(err error) {
+	logger.Fatal(err.Error())
+	exit(1)
+}
+
+// exit closes the encryptor, which stops the command of a persistent exec encryptor, and exits with code
+func exit(code int) {
+	if encryptor != nil {
+		if err := storage.CloseEncryptor(encryptor); err != nil {
+			logger.Warn("failed to close encryptor", logger.Err(err))
+		}
+	}
+	os.Exit(code)
+}
+
+func main() {
 	var err error
 	conf, err = config.NewKaigaraConfig()
 	if err != nil {
 		panic(err)
 	}
 
-	ss, err := storage.GetStorageService(conf)
+	l, err := config.NewLogger(conf, "Kaigara")
 	if err != nil {
 		panic(err)
 	}
+	logger.SetDefault(l.With(logger.F("deployment_id", conf.DeploymentID)))
+	logger.Info("starting Kaigara", logger.F("kaigara_version", Version))
 
-	restart = make(chan int)
+	if rules, err = env.NewRules(conf); err != nil {
+		fatal(err)
+	}
 
-	for {
-		kaigaraRun(ss, os.Args[1], os.Args[2:])
+	procs, err := loadProcesses(parseExecFlag(os.Args[1:]))
+	if err != nil {
+		fatal(err)
+	}
 
-		select {
-		case <-restart:
-			continue
-		default:
-			return
+	encryptor, err = storage.NewEncryptor(conf)
+	if err != nil {
+		fatal(err)
+	}
+	if conf.MetricsAddr != "" {
+		encryptor = metrics.NewEncryptor(encryptor)
+	}
+
+	if conf.SnapshotPath != "" {
+		if snapshotKey, err = loadSnapshotKey(); err != nil {
+			fatal(err)
+		}
+	}
+
+	ss, err := storage.NewStorageService(conf, encryptor)
+	if err != nil {
+		if snapshotKey == nil {
+			fatal(err)
 		}
+		// The storage is connected again when the secrets are loaded, and in background while the snapshot is used
+		logger.Error("failed to connect to the secret storage", logger.Err(err))
+		ss = storage.NewLazyStorage(func() (types.Storage, error) {
+			return storage.NewStorageService(conf, encryptor)
+		})
 	}
+
+	// In exec mode, kaigara is replaced by the command once its environment is built
+	if conf.Exec {
+		if err := kaigaraExec(ss, procs); err != nil {
+			fatal(err)
+		}
+	}
+
+	if conf.MetricsAddr != "" {
+		ss = metrics.NewStorage(ss)
+		if err := serveMetrics(conf.MetricsAddr); err != nil {
+			fatal(err)
+		}
+	}
+
+	restartSignal, err = parseSignal(conf.RestartSignal)
+	if err != nil {
+		fatal(err)
+	}
+
+	onChange, err = parseOnChange(conf.OnChange)
+	if err != nil {
+		fatal(err)
+	}
+
+	if err := checkRestartPolicy(conf.RestartPolicy); err != nil {
+		fatal(err)
+	}
+
+	// Signals are caught for the whole life of kaigara, so none is missed between restarts
+	received = make(chan os.Signal, 16)
+	signal.Notify(received)
+
+	if conf.Init {
+		if err := startInit(); err != nil {
+			fatal(err)
+		}
+	}
+
+	exit(kaigaraSupervise(ss, procs))
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/openware/kaigara/pkg/config"
 	"github.com/openware/kaigara/pkg/sql"
 	"github.com/openware/kaigara/pkg/storage"
+	"github.com/openware/kaigara/types"
 	"github.com/openware/kaigara/utils/testenv"
 	"gorm.io/gorm"
 )
@@ -36,6 +37,21 @@ var vars = []string{
 
 var testdataPath = "../testdata/testenv.yml"
 
+// runCmd runs the command with the environment built from the storage and tells if it has to be restarted
+func runCmd(t *testing.T, ss types.Storage, cmd string, cmdArgs ...string) (bool, int) {
+	envs, err := buildEnv(ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reason, code, err := kaigaraRun(ss, envs, []*process{newProcess("", cmd, cmdArgs)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return reason == secretsUpdated, code
+}
+
 func TestMain(m *testing.M) {
 	var err error
 	if conf, err = config.NewKaigaraConfig(); err != nil {
@@ -54,7 +70,7 @@ func TestKaigaraPrintenvVault(t *testing.T) {
 	ss := testenv.GetTestStorage(testdataPath, conf)
 
 	for _, v := range vars {
-		kaigaraRun(ss, "printenv", []string{v})
+		runCmd(t, ss, "printenv", v)
 	}
 
 	appNames := strings.Split(conf.AppNames, ",")
@@ -70,7 +86,7 @@ func TestKaigaraPrintenvSql(t *testing.T) {
 	ss := testenv.GetTestStorage(testdataPath, conf)
 
 	for _, v := range vars {
-		kaigaraRun(ss, "printenv", []string{v})
+		runCmd(t, ss, "printenv", v)
 	}
 
 	// Cleanup data
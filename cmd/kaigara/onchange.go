@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/openware/kaigara/pkg/config"
+	encexec "github.com/openware/kaigara/pkg/encryptor/exec"
+	"github.com/openware/kaigara/pkg/logger"
+)
+
+// Actions taken on secrets change, set with KAIGARA_ON_CHANGE
+const (
+	onChangeRestart = "restart"
+	onChangeSignal  = "signal"
+	onChangeExec    = "exec"
+)
+
+// changeAction tells how the running command is notified of secrets changes
+type changeAction struct {
+	mode    string
+	signal  os.Signal
+	command []string
+}
+
+// parseOnChange parses restart, signal:<SIG> or exec:<command>
+func parseOnChange(value string) (*changeAction, error) {
+	mode, arg := value, ""
+	if i := strings.Index(value, ":"); i >= 0 {
+		mode, arg = value[:i], value[i+1:]
+	}
+
+	switch mode {
+	case onChangeRestart, "":
+		return &changeAction{mode: onChangeRestart}, nil
+
+	case onChangeSignal:
+		sig, err := parseSignal(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid on change action '%s': %s", value, err)
+		}
+		return &changeAction{mode: onChangeSignal, signal: sig}, nil
+
+	case onChangeExec:
+		command, err := encexec.SplitCommand(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid on change action '%s': %s", value, err)
+		}
+		if len(command) == 0 {
+			return nil, fmt.Errorf("invalid on change action '%s': command is empty", value)
+		}
+		return &changeAction{mode: onChangeExec, command: command}, nil
+
+	default:
+		return nil, fmt.Errorf("on change action '%s' is not supported, use restart, signal:<SIG> or exec:<command>", value)
+	}
+}
+
+// reloadProcesses rewrites the changed files and notifies the running processes with the on change action.
+// Since the environment of a running process can't be changed, an error is returned if a variable changed,
+// as well as if the processes couldn't be notified, they have to be restarted then.
+func reloadProcesses(procs []*process, next *config.Env, vars, files []string) error {
+	if len(vars) > 0 {
+		return fmt.Errorf("environment variables changed")
+	}
+
+	if err := writeFiles(next.Files); err != nil {
+		return err
+	}
+
+	switch onChange.mode {
+	case onChangeSignal:
+		for _, p := range procs {
+			if !p.running {
+				continue
+			}
+			if err := signalCmd(p.c, onChange.signal); err != nil {
+				return fmt.Errorf("failed to send %s to %s: %s", signalName(onChange.signal), p.label(), err)
+			}
+			logger.Info("sent reload signal", p.logFields(logger.F("signal", signalName(onChange.signal)))...)
+		}
+
+	case onChangeExec:
+		reload := exec.Command(onChange.command[0], onChange.command[1:]...)
+		reload.Env = next.Vars
+		reload.Stdout = os.Stdout
+		reload.Stderr = os.Stderr
+
+		if err := reload.Run(); err != nil {
+			return fmt.Errorf("reload command failed: %s", err)
+		}
+		logger.Info("ran reload command", logger.F("cmd", strings.Join(onChange.command, " ")))
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+//go:build !windows
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/k8s"
+	"github.com/openware/kaigara/types"
+)
+
+// newReloadStorage returns the storage read by kaigara and a second one on the same cluster to update the secrets
+func newReloadStorage(t *testing.T, entries map[string]interface{}) (types.Storage, types.Storage) {
+	conf.AppNames = "finex"
+	conf.Scopes = "secret"
+
+	client := k8s.NewMockClient()
+	ss := k8s.NewMockService(client)
+	writer := k8s.NewMockService(client)
+
+	updateSecrets(t, writer, entries)
+
+	return ss, writer
+}
+
+func updateSecrets(t *testing.T, ss types.Storage, entries map[string]interface{}) {
+	assert.NoError(t, ss.Read("finex", "secret"))
+	assert.NoError(t, ss.SetEntries("finex", "secret", entries))
+	assert.NoError(t, ss.Write("finex", "secret"))
+}
+
+// brokenStorage fails to return the entries while it's broken, like when they can't be decrypted
+type brokenStorage struct {
+	types.Storage
+	broken int32
+}
+
+func (bs *brokenStorage) GetEntries(appName, scope string) (map[string]interface{}, error) {
+	if atomic.LoadInt32(&bs.broken) == 1 {
+		return nil, errors.New("failed to decrypt")
+	}
+
+	return bs.Storage.GetEntries(appName, scope)
+}
+
+func withOnChange(t *testing.T, value string) func() {
+	action, err := parseOnChange(value)
+	assert.NoError(t, err)
+
+	onChange, pollInterval, restartSignal = action, 50*time.Millisecond, syscall.SIGTERM
+	received = make(chan os.Signal, 1)
+
+	return func() {
+		onChange, pollInterval, received = &changeAction{mode: onChangeRestart}, 5*time.Second, nil
+	}
+}
+
+func TestKaigaraRunReloadSignal(t *testing.T) {
+	dir := t.TempDir()
+	cert, copy := path.Join(dir, "cert.pem"), path.Join(dir, "copy.pem")
+
+	ss, writer := newReloadStorage(t, map[string]interface{}{
+		"kfile_cert_path":    cert,
+		"kfile_cert_content": base64.StdEncoding.EncodeToString([]byte("v1")),
+		"finex_host":         "0.0.0.0",
+	})
+	defer withOnChange(t, "signal:SIGHUP")()
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		updateSecrets(t, writer, map[string]interface{}{
+			"kfile_cert_content": base64.StdEncoding.EncodeToString([]byte("v2")),
+		})
+		time.Sleep(500 * time.Millisecond)
+		received <- syscall.SIGTERM
+	}()
+
+	script := `trap "cp ` + cert + ` ` + copy + `" HUP; trap "exit 0" TERM; while true; do sleep 0.05; done`
+	restart, code := runCmd(t, ss, "sh", "-c", script)
+	assert.False(t, restart)
+	assert.Equal(t, 0, code)
+
+	content, err := os.ReadFile(copy)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(content))
+}
+
+func TestKaigaraRunReloadExec(t *testing.T) {
+	dir := t.TempDir()
+	cert, copy := path.Join(dir, "cert.pem"), path.Join(dir, "copy.pem")
+
+	ss, writer := newReloadStorage(t, map[string]interface{}{
+		"kfile_cert_path":    cert,
+		"kfile_cert_content": base64.StdEncoding.EncodeToString([]byte("v1")),
+	})
+	defer withOnChange(t, "exec:cp "+cert+" "+copy)()
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		updateSecrets(t, writer, map[string]interface{}{
+			"kfile_cert_content": base64.StdEncoding.EncodeToString([]byte("v2")),
+		})
+		time.Sleep(500 * time.Millisecond)
+		received <- syscall.SIGTERM
+	}()
+
+	restart, code := runCmd(t, ss, "sh", "-c", `trap "exit 0" TERM; while true; do sleep 0.05; done`)
+	assert.False(t, restart)
+	assert.Equal(t, 0, code)
+
+	content, err := os.ReadFile(copy)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(content))
+}
+
+func TestKaigaraRunReloadFallbackToRestart(t *testing.T) {
+	dir := t.TempDir()
+	cert := path.Join(dir, "cert.pem")
+
+	ss, writer := newReloadStorage(t, map[string]interface{}{
+		"kfile_cert_path":    cert,
+		"kfile_cert_content": base64.StdEncoding.EncodeToString([]byte("v1")),
+		"finex_host":         "0.0.0.0",
+	})
+	defer withOnChange(t, "signal:SIGHUP")()
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		updateSecrets(t, writer, map[string]interface{}{
+			"kfile_cert_content": base64.StdEncoding.EncodeToString([]byte("v2")),
+			"finex_host":         "127.0.0.1",
+		})
+	}()
+
+	restart, _ := runCmd(t, ss, "sh", "-c", `trap "exit 0" TERM; while true; do sleep 0.05; done`)
+	assert.True(t, restart)
+}
+
+func TestParseOnChange(t *testing.T) {
+	action, err := parseOnChange("restart")
+	assert.NoError(t, err)
+	assert.Equal(t, onChangeRestart, action.mode)
+
+	action, err = parseOnChange("signal:HUP")
+	assert.NoError(t, err)
+	assert.Equal(t, onChangeSignal, action.mode)
+	assert.Equal(t, syscall.SIGHUP, action.signal)
+
+	action, err = parseOnChange("exec:nginx -s reload")
+	assert.NoError(t, err)
+	assert.Equal(t, onChangeExec, action.mode)
+	assert.Equal(t, []string{"nginx", "-s", "reload"}, action.command)
+
+	action, err = parseOnChange(`exec:nginx -s "reload now" 'a b'`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"nginx", "-s", "reload now", "a b"}, action.command)
+
+	_, err = parseOnChange(`exec:nginx -s "reload`)
+	assert.ErrorContains(t, err, "unterminated \" quote in command")
+
+	for _, value := range []string{"signal:SIGNOPE", "exec:", "exec: ", "reload"} {
+		_, err = parseOnChange(value)
+		assert.Error(t, err)
+	}
+}
+
+func TestKaigaraRunUnchangedEnv(t *testing.T) {
+	entries := map[string]interface{}{"finex_host": "0.0.0.0"}
+	ss, writer := newReloadStorage(t, entries)
+	defer withOnChange(t, "restart")()
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		updateSecrets(t, writer, entries)
+		time.Sleep(500 * time.Millisecond)
+		received <- syscall.SIGTERM
+	}()
+
+	restart, code := runCmd(t, ss, "sh", "-c", `trap "exit 3" TERM; while true; do sleep 0.05; done`)
+	assert.False(t, restart)
+	assert.Equal(t, 3, code)
+}
+
+func TestKaigaraRunRetryFailedEnv(t *testing.T) {
+	ss, writer := newReloadStorage(t, map[string]interface{}{"finex_host": "0.0.0.0"})
+	bs := &brokenStorage{Storage: ss}
+	defer withOnChange(t, "restart")()
+
+	envs, err := loadEnv(bs)
+	assert.NoError(t, err)
+
+	atomic.StoreInt32(&bs.broken, 1)
+	go func() {
+		// The process keeps running while the environment can't be built, it's retried on the next polls
+		time.Sleep(200 * time.Millisecond)
+		updateSecrets(t, writer, map[string]interface{}{"finex_host": "127.0.0.1"})
+		time.Sleep(300 * time.Millisecond)
+		atomic.StoreInt32(&bs.broken, 0)
+	}()
+
+	proc := newProcess("", "sh", []string{"-c", `trap "exit 0" TERM; while true; do sleep 0.05; done`})
+	start := time.Now()
+	reason, _, err := kaigaraRun(bs, envs, []*process{proc})
+	assert.NoError(t, err)
+	assert.Equal(t, secretsUpdated, reason)
+	assert.Greater(t, time.Since(start), 500*time.Millisecond)
+}
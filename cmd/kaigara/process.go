@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/openware/kaigara/pkg/logger"
+	"github.com/openware/kaigara/pkg/metrics"
+)
+
+// process is a command run by kaigara, the single command given in arguments or one of the Procfile
+type process struct {
+	name     string
+	cmd      string
+	args     []string
+	restarts *restarter
+
+	c       *exec.Cmd
+	exited  chan struct{}
+	running bool
+	stdout  *prefixWriter
+	stderr  *prefixWriter
+}
+
+// newProcess returns a process running cmd, its output is prefixed with its name unless it's empty
+func newProcess(name, cmd string, args []string) *process {
+	return &process{
+		name:     name,
+		cmd:      cmd,
+		args:     args,
+		restarts: newRestarter(conf.RestartBackoff, conf.RestartBackoffMax, conf.RestartLimit, conf.RestartWindow),
+	}
+}
+
+// label returns the name of the process to use in logs
+func (p *process) label() string {
+	if p.name == "" {
+		return "process"
+	}
+
+	return fmt.Sprintf("process %s", p.name)
+}
+
+// logFields returns the fields identifying the process in logs, followed by fields
+func (p *process) logFields(fields ...logger.Field) []logger.Field {
+	return append([]logger.Field{logger.F("process", p.metricName())}, fields...)
+}
+
+// metricName returns the name of the process in metrics
+func (p *process) metricName() string {
+	if p.name == "" {
+		return "main"
+	}
+
+	return p.name
+}
+
+// start starts the process with the environment vars and sends it to exits once it exited
+func (p *process) start(vars []string, exits chan<- *process) error {
+	p.c = exec.Command(p.cmd, p.args...)
+	p.c.Env = vars
+	if p.stdout != nil {
+		p.c.Stdout = p.stdout
+		p.c.Stderr = p.stderr
+	} else {
+		p.c.Stdout = os.Stdout
+		p.c.Stdin = os.Stdin
+		p.c.Stderr = os.Stderr
+	}
+	if groupProcesses() {
+		setProcessGroup(p.c)
+	}
+
+	logger.Info("starting command", p.logFields(logger.F("cmd", p.cmd), logger.F("args", p.args))...)
+	if err := startManaged(p.c); err != nil {
+		return fmt.Errorf("failed to start command: %s", err)
+	}
+
+	p.running = true
+	p.exited = make(chan struct{})
+	metrics.ProcessStarted(p.metricName(), p.c.Process.Pid)
+
+	go func(c *exec.Cmd, exited chan struct{}) {
+		if err := c.Wait(); err != nil {
+			logger.Info("process completed", p.logFields(logger.F("pid", c.Process.Pid), logger.Err(err))...)
+		}
+		releaseManaged(c.Process.Pid)
+		// In init mode, the subprocesses of the process don't outlive it, e.g. across restarts
+		if conf.Init {
+			killProcessGroup(c)
+		}
+		metrics.ProcessExited(p.metricName())
+		if p.stdout != nil {
+			p.stdout.Flush()
+			p.stderr.Flush()
+		}
+		close(exited)
+		exits <- p
+	}(p.c, p.exited)
+
+	return nil
+}
+
+// stopProcesses stops the running processes in parallel
+func stopProcesses(procs []*process, sig os.Signal, grace time.Duration) {
+	var wg sync.WaitGroup
+	for _, p := range procs {
+		if !p.running {
+			continue
+		}
+
+		wg.Add(1)
+		go func(p *process) {
+			defer wg.Done()
+			stopProcess(p.c, p.exited, sig, grace)
+		}(p)
+	}
+	wg.Wait()
+
+	for _, p := range procs {
+		p.running = false
+	}
+}
+
+// prefixOutputs prefixes each line written by the processes with their name, aligned on the longest one
+func prefixOutputs(procs []*process) {
+	width := 0
+	for _, p := range procs {
+		if len(p.name) > width {
+			width = len(p.name)
+		}
+	}
+
+	mu := &sync.Mutex{}
+	for _, p := range procs {
+		prefix := []byte(fmt.Sprintf("%-*s | ", width, p.name))
+		p.stdout = &prefixWriter{mu: mu, out: os.Stdout, prefix: prefix}
+		p.stderr = &prefixWriter{mu: mu, out: os.Stderr, prefix: prefix}
+	}
+}
+
+// prefixWriter writes each complete line prefixed, the lock is shared by all processes so their lines aren't mixed
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+	}
+	w.buf = append([]byte(nil), w.buf...)
+
+	return len(data), nil
+}
+
+// Flush writes the last line if it doesn't end with a new line
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.out.Write(append(append([]byte{}, w.prefix...), line...))
+}
@@ -0,0 +1,189 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/config"
+	"github.com/openware/kaigara/pkg/k8s"
+	"github.com/openware/kaigara/types"
+)
+
+func newMockStorage(t *testing.T) types.Storage {
+	conf.AppNames = "finex"
+	conf.Scopes = "secret"
+
+	return k8s.NewMockService(k8s.NewMockClient())
+}
+
+func TestKaigaraRunExitCode(t *testing.T) {
+	ss := newMockStorage(t)
+
+	restart, code := runCmd(t, ss, "sh", "-c", "exit 5")
+	assert.False(t, restart)
+	assert.Equal(t, 5, code)
+
+	restart, code = runCmd(t, ss, "sh", "-c", "kill -KILL $$")
+	assert.False(t, restart)
+	assert.Equal(t, 128+int(syscall.SIGKILL), code)
+}
+
+func TestKaigaraRunForwardSignals(t *testing.T) {
+	ss := newMockStorage(t)
+
+	received = make(chan os.Signal, 1)
+	defer func() { received = nil }()
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		received <- syscall.SIGURG
+		received <- syscall.SIGTERM
+	}()
+
+	restart, code := runCmd(t, ss, "sh", "-c", `trap "exit 3" TERM; while true; do sleep 0.05; done`)
+	assert.False(t, restart)
+	assert.Equal(t, 3, code)
+}
+
+func TestKaigaraRunProcessGroup(t *testing.T) {
+	ss := newMockStorage(t)
+
+	conf.SignalProcessGroup = true
+	received = make(chan os.Signal, 1)
+	defer func() {
+		conf.SignalProcessGroup = false
+		received = nil
+	}()
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		received <- syscall.SIGTERM
+	}()
+
+	// The signal reaches the sleep child too, so the shell doesn't wait for it to complete
+	restart, code := runCmd(t, ss, "sh", "-c", `trap "exit 4" TERM; sleep 10; wait`)
+	assert.False(t, restart)
+	assert.Equal(t, 4, code)
+}
+
+func withRestarts(limit int, rollback time.Duration) func() {
+	conf.RestartBackoff, conf.RestartBackoffMax = 10*time.Millisecond, 50*time.Millisecond
+	conf.RestartLimit, conf.RestartWindow, conf.RollbackPeriod = limit, time.Minute, rollback
+
+	return func() {
+		conf.RestartBackoff, conf.RestartBackoffMax = time.Second, time.Minute
+		conf.RestartLimit, conf.RestartWindow, conf.RollbackPeriod = 10, 10*time.Minute, 0
+	}
+}
+
+func TestKaigaraSuperviseRestartLimit(t *testing.T) {
+	ss, _ := newReloadStorage(t, map[string]interface{}{"finex_host": "0"})
+	defer withOnChange(t, "restart")()
+	defer withRestarts(2, 0)()
+
+	conf.RestartPolicy = restartAlways
+	defer func() { conf.RestartPolicy = restartNever }()
+
+	code := kaigaraSupervise(ss, []*process{newProcess("", "sh", []string{"-c", "exit 3"})})
+	assert.Equal(t, 1, code)
+}
+
+func TestKaigaraSuperviseSecretsUpdates(t *testing.T) {
+	ss, writer := newReloadStorage(t, map[string]interface{}{"finex_host": "0"})
+	defer withOnChange(t, "restart")()
+	defer withRestarts(2, 0)()
+
+	// Restarts following secret updates don't count as crashes
+	go func() {
+		for i := 1; i <= 5; i++ {
+			time.Sleep(200 * time.Millisecond)
+			updateSecrets(t, writer, map[string]interface{}{"finex_host": strconv.Itoa(i)})
+		}
+		time.Sleep(300 * time.Millisecond)
+		received <- syscall.SIGTERM
+	}()
+
+	code := kaigaraSupervise(ss, []*process{newProcess("", "sh", []string{"-c", `trap "exit 0" TERM; while true; do sleep 0.05; done`})})
+	assert.Equal(t, 0, code)
+}
+
+func TestKaigaraSuperviseRollback(t *testing.T) {
+	ss, writer := newReloadStorage(t, map[string]interface{}{"finex_mode": "good"})
+	defer withOnChange(t, "restart")()
+	defer withRestarts(10, 300*time.Millisecond)()
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		updateSecrets(t, writer, map[string]interface{}{"finex_mode": "bad"})
+		time.Sleep(500 * time.Millisecond)
+		received <- syscall.SIGTERM
+	}()
+
+	script := `[ "$FINEX_MODE" = good ] || exit 7; trap "exit 0" TERM; while true; do sleep 0.05; done`
+	code := kaigaraSupervise(ss, []*process{newProcess("", "sh", []string{"-c", script})})
+	assert.Equal(t, 0, code)
+}
+
+func TestKaigaraSuperviseNoRollback(t *testing.T) {
+	ss, writer := newReloadStorage(t, map[string]interface{}{"finex_mode": "good"})
+	defer withOnChange(t, "restart")()
+	defer withRestarts(10, 0)()
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		updateSecrets(t, writer, map[string]interface{}{"finex_mode": "bad"})
+	}()
+
+	script := `[ "$FINEX_MODE" = good ] || exit 7; trap "exit 0" TERM; while true; do sleep 0.05; done`
+	code := kaigaraSupervise(ss, []*process{newProcess("", "sh", []string{"-c", script})})
+	assert.Equal(t, 7, code)
+}
+
+func TestKaigaraSuperviseRestartPolicy(t *testing.T) {
+	ss, writer := newReloadStorage(t, map[string]interface{}{"finex_mode": "bad"})
+	defer withOnChange(t, "restart")()
+	defer withRestarts(100, 0)()
+
+	conf.RestartPolicy = restartAlways
+	defer func() { conf.RestartPolicy = restartNever }()
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		updateSecrets(t, writer, map[string]interface{}{"finex_mode": "good"})
+		time.Sleep(500 * time.Millisecond)
+		received <- syscall.SIGTERM
+	}()
+
+	// The crashing command is restarted with the secrets read again, and isn't restarted anymore once asked to stop
+	script := `[ "$FINEX_MODE" = good ] || exit 7; trap "exit 0" TERM; while true; do sleep 0.05; done`
+	code := kaigaraSupervise(ss, []*process{newProcess("", "sh", []string{"-c", script})})
+	assert.Equal(t, 0, code)
+}
+
+func TestKaigaraRunCleanup(t *testing.T) {
+	conf.KfileCleanup = true
+	defer func() { conf.KfileCleanup = false }()
+
+	path := filepath.Join(t.TempDir(), "app.yml")
+	envs := &config.Env{Files: map[string]*config.File{
+		"CONFIG": {Path: path, Content: "app: finex", Encoding: "raw"},
+	}}
+
+	// The file exists while the process runs and is removed once it stopped
+	proc := newProcess("", "sh", []string{"-c", "test -f " + path})
+	for i := 0; i < 2; i++ {
+		reason, code, err := kaigaraRun(newMockStorage(t), envs, []*process{proc})
+		assert.NoError(t, err)
+		assert.Equal(t, processExited, reason)
+		assert.Equal(t, 0, code)
+		assert.NoFileExists(t, path)
+	}
+}
@@ -0,0 +1,52 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/openware/kaigara/pkg/logger"
+)
+
+// setProcessGroup starts the command in its own process group
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcess sends the signal to the process, or to its whole process group
+func signalProcess(c *exec.Cmd, sig os.Signal, group bool) error {
+	if s, ok := sig.(syscall.Signal); ok && group {
+		return syscall.Kill(-c.Process.Pid, s)
+	}
+
+	return c.Process.Signal(sig)
+}
+
+// killProcessGroup kills the processes left in the process group of the command once it exited
+func killProcessGroup(c *exec.Cmd) {
+	if err := syscall.Kill(-c.Process.Pid, syscall.SIGKILL); err == nil {
+		logger.Info("killed the processes left in the process group", logger.F("pgid", c.Process.Pid))
+	}
+}
+
+// exitCode returns the exit code of the process, or 128+signal if it was killed by a signal
+func exitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return state.ExitCode()
+}
+
+// isForwarded tells if a signal received by kaigara is forwarded to the command,
+// SIGURG is used internally by the Go runtime and SIGCHLD is about kaigara's own children
+func isForwarded(sig os.Signal) bool {
+	return sig != syscall.SIGURG && sig != syscall.SIGCHLD
+}
+
+// shellCommand returns the command running the line with the shell
+func shellCommand(line string) (string, []string) {
+	return "sh", []string{"-c", line}
+}
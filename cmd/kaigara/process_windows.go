@@ -0,0 +1,35 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is not supported on Windows
+func setProcessGroup(c *exec.Cmd) {}
+
+// signalProcess sends the signal to the process, only os.Kill is supported on Windows
+func signalProcess(c *exec.Cmd, sig os.Signal, group bool) error {
+	return c.Process.Signal(sig)
+}
+
+// killProcessGroup is not supported on Windows
+func killProcessGroup(c *exec.Cmd) {}
+
+// exitCode returns the exit code of the process
+func exitCode(state *os.ProcessState) int {
+	return state.ExitCode()
+}
+
+// isForwarded tells if a signal received by kaigara is forwarded to the command,
+// console signals are delivered to the command by Windows itself
+func isForwarded(sig os.Signal) bool {
+	return false
+}
+
+// shellCommand returns the command running the line with the shell
+func shellCommand(line string) (string, []string) {
+	return "cmd", []string{"/C", line}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Actions taken when a process of the Procfile exits, set with KAIGARA_PROCFILE_ON_EXIT
+const (
+	procfileRestart = "restart"
+	procfileStop    = "stop"
+)
+
+var procfileLine = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.+)$`)
+
+// loadProcesses returns the processes of the Procfile if one is configured, or the command given in arguments otherwise
+func loadProcesses(args []string) ([]*process, error) {
+	if conf.Procfile == "" {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("Usage: kaigara CMD [ARGS...]")
+		}
+		return []*process{newProcess("", args[0], args[1:])}, nil
+	}
+
+	if len(args) > 0 {
+		return nil, fmt.Errorf("a command can't be given when KAIGARA_PROCFILE is set")
+	}
+	if err := checkProcfileOnExit(conf.ProcfileOnExit); err != nil {
+		return nil, err
+	}
+
+	procs, err := loadProcfile(conf.Procfile)
+	if err != nil {
+		return nil, err
+	}
+	prefixOutputs(procs)
+
+	return procs, nil
+}
+
+// loadProcfile reads the processes of a Procfile, each line is 'name: command' and the command is run by the shell
+func loadProcfile(path string) ([]*process, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Procfile: %s", err)
+	}
+	defer file.Close()
+
+	procs := []*process{}
+	names := map[string]bool{}
+
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		m := procfileLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("invalid Procfile line %d: %s", n, line)
+		}
+		if names[m[1]] {
+			return nil, fmt.Errorf("duplicated process '%s' in Procfile line %d", m[1], n)
+		}
+		names[m[1]] = true
+
+		cmd, args := shellCommand(m[2])
+		procs = append(procs, newProcess(m[1], cmd, args))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Procfile: %s", err)
+	}
+
+	if len(procs) == 0 {
+		return nil, fmt.Errorf("no process found in Procfile %s", path)
+	}
+
+	return procs, nil
+}
+
+// checkProcfileOnExit returns an error if the action taken when a process of the Procfile exits is unknown
+func checkProcfileOnExit(action string) error {
+	switch action {
+	case procfileRestart, procfileStop:
+		return nil
+	default:
+		return fmt.Errorf("procfile on exit action '%s' is not supported, use %s or %s", action, procfileRestart, procfileStop)
+	}
+}
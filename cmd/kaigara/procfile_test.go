@@ -0,0 +1,128 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeProcfile(t *testing.T, content string) string {
+	procfile := path.Join(t.TempDir(), "Procfile")
+	assert.NoError(t, os.WriteFile(procfile, []byte(content), 0640))
+
+	return procfile
+}
+
+func TestLoadProcfile(t *testing.T) {
+	procs, err := loadProcfile(writeProcfile(t, "# Processes\nweb: bundle exec puma -p $PORT\n\nworker:  bin/worker\n"))
+	assert.NoError(t, err)
+	assert.Len(t, procs, 2)
+	assert.Equal(t, "web", procs[0].name)
+	assert.Equal(t, "sh", procs[0].cmd)
+	assert.Equal(t, []string{"-c", "bundle exec puma -p $PORT"}, procs[0].args)
+	assert.Equal(t, "worker", procs[1].name)
+	assert.Equal(t, []string{"-c", "bin/worker"}, procs[1].args)
+
+	for _, content := range []string{"", "# empty\n", "web bin/web\n", "web: bin/web\nweb: bin/other\n"} {
+		_, err := loadProcfile(writeProcfile(t, content))
+		assert.Error(t, err)
+	}
+}
+
+func TestPrefixOutputs(t *testing.T) {
+	procs := []*process{newProcess("web", "true", nil), newProcess("worker", "true", nil)}
+	prefixOutputs(procs)
+
+	out := &bytes.Buffer{}
+	procs[0].stdout.out = out
+	procs[1].stdout.out = out
+
+	procs[0].stdout.Write([]byte("listening\nstar"))
+	procs[1].stdout.Write([]byte("waiting for jobs\n"))
+	procs[0].stdout.Write([]byte("ted"))
+	procs[0].stdout.Flush()
+
+	assert.Equal(t, "web    | listening\nworker | waiting for jobs\nweb    | started\n", out.String())
+}
+
+func withProcfileOnExit(action string) func() {
+	conf.ProcfileOnExit = action
+	return func() { conf.ProcfileOnExit = procfileStop }
+}
+
+func TestKaigaraRunProcfileStop(t *testing.T) {
+	ss, _ := newReloadStorage(t, map[string]interface{}{"finex_host": "0.0.0.0"})
+	defer withOnChange(t, "restart")()
+	defer withProcfileOnExit(procfileStop)()
+
+	envs, err := buildEnv(ss)
+	assert.NoError(t, err)
+
+	web := newProcess("web", "sh", []string{"-c", `trap "exit 0" TERM; while true; do sleep 0.05; done`})
+	worker := newProcess("worker", "sh", []string{"-c", "sleep 0.2; exit 3"})
+
+	reason, code, err := kaigaraRun(ss, envs, []*process{web, worker})
+	assert.NoError(t, err)
+	assert.Equal(t, processExited, reason)
+	assert.Equal(t, 3, code)
+	assert.True(t, web.c.ProcessState.Exited())
+	assert.Equal(t, 0, web.c.ProcessState.ExitCode())
+}
+
+func TestKaigaraRunProcfileRestart(t *testing.T) {
+	starts := path.Join(t.TempDir(), "starts")
+	ss, _ := newReloadStorage(t, map[string]interface{}{"finex_host": "0.0.0.0"})
+	defer withOnChange(t, "restart")()
+	defer withProcfileOnExit(procfileRestart)()
+	defer withRestarts(3, 0)()
+
+	envs, err := buildEnv(ss)
+	assert.NoError(t, err)
+
+	web := newProcess("web", "sh", []string{"-c", `trap "exit 0" TERM; while true; do sleep 0.05; done`})
+	worker := newProcess("worker", "sh", []string{"-c", "echo started >> " + starts + "; exit 2"})
+
+	// The worker is restarted alone until it reaches the restart limit, then everything is stopped
+	reason, code, err := kaigaraRun(ss, envs, []*process{web, worker})
+	assert.NoError(t, err)
+	assert.Equal(t, processExited, reason)
+	assert.Equal(t, 2, code)
+	assert.Equal(t, 0, web.c.ProcessState.ExitCode())
+
+	content, err := os.ReadFile(starts)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(content), "started"))
+}
+
+func TestKaigaraRunProcfileSecretsUpdated(t *testing.T) {
+	ss, writer := newReloadStorage(t, map[string]interface{}{"finex_host": "0.0.0.0"})
+	defer withOnChange(t, "restart")()
+
+	envs, err := buildEnv(ss)
+	assert.NoError(t, err)
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		updateSecrets(t, writer, map[string]interface{}{"finex_host": "127.0.0.1"})
+	}()
+
+	procs := []*process{
+		newProcess("web", "sh", []string{"-c", `trap "exit 0" TERM; while true; do sleep 0.05; done`}),
+		newProcess("worker", "sh", []string{"-c", `trap "exit 0" TERM; while true; do sleep 0.05; done`}),
+	}
+
+	reason, _, err := kaigaraRun(ss, envs, procs)
+	assert.NoError(t, err)
+	assert.Equal(t, secretsUpdated, reason)
+	for _, p := range procs {
+		assert.False(t, p.running)
+		assert.True(t, p.c.ProcessState.Exited())
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/openware/kaigara/pkg/logger"
+	"github.com/openware/kaigara/pkg/metrics"
+)
+
+// Restart policies of the command when it exits, set with KAIGARA_RESTART_POLICY
+const (
+	restartAlways    = "always"
+	restartOnFailure = "on-failure"
+	restartNever     = "never"
+)
+
+// checkRestartPolicy returns an error if the restart policy is unknown
+func checkRestartPolicy(policy string) error {
+	switch policy {
+	case restartAlways, restartOnFailure, restartNever:
+		return nil
+	default:
+		return fmt.Errorf("restart policy '%s' is not supported, use %s, %s or %s", policy, restartAlways, restartOnFailure, restartNever)
+	}
+}
+
+// restartOnExit tells if the command has to be restarted after it exited with the code
+func restartOnExit(policy string, code int) bool {
+	switch policy {
+	case restartAlways:
+		return true
+	case restartOnFailure:
+		return code != 0
+	default:
+		return false
+	}
+}
+
+// logRestart counts a restart of the processes and logs it as a restart event, with the reason and the fields
+func logRestart(reason string, fields ...logger.Field) {
+	metrics.Restarted(reason)
+	logger.Info("restarting process", append([]logger.Field{logger.F("event", "restart"), logger.F("reason", reason)}, fields...)...)
+}
+
+// stopProcess asks the process to stop with sig and kills it if it's still running after the grace period.
+// exited must be closed once the process is waited for.
+func stopProcess(c *exec.Cmd, exited <-chan struct{}, sig os.Signal, grace time.Duration) {
+	start := time.Now()
+	name := signalName(sig)
+
+	logger.Info("stopping process", logger.F("pid", c.Process.Pid), logger.F("signal", name), logger.F("grace_period", grace))
+	if err := signalCmd(c, sig); err != nil {
+		logger.Warn("failed to signal process, killing it", logger.F("pid", c.Process.Pid), logger.F("signal", name), logger.Err(err))
+		grace = 0
+	}
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	select {
+	case <-exited:
+		logger.Info("process exited", logger.F("pid", c.Process.Pid), logger.F("signal", name), logger.F("after", time.Since(start).Round(time.Millisecond)))
+		return
+	case <-timer.C:
+	}
+
+	if grace > 0 {
+		logger.Warn("process still running after the grace period, killing it", logger.F("pid", c.Process.Pid), logger.F("signal", name), logger.F("grace_period", grace))
+	}
+	if err := signalCmd(c, os.Kill); err != nil {
+		logger.Error("failed to kill process", logger.F("pid", c.Process.Pid), logger.Err(err))
+	}
+
+	<-exited
+	logger.Info("process killed", logger.F("pid", c.Process.Pid), logger.F("signal", name), logger.F("after", time.Since(start).Round(time.Millisecond)))
+}
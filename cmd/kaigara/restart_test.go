@@ -0,0 +1,74 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startProcess(t *testing.T, script string) (*exec.Cmd, chan struct{}) {
+	c := exec.Command("sh", "-c", script)
+	assert.NoError(t, c.Start())
+
+	exited := make(chan struct{})
+	go func() {
+		c.Wait()
+		close(exited)
+	}()
+
+	// Let the shell install its traps
+	time.Sleep(100 * time.Millisecond)
+
+	return c, exited
+}
+
+func TestStopProcessGraceful(t *testing.T) {
+	c, exited := startProcess(t, `trap "exit 0" TERM; while true; do sleep 0.05; done`)
+
+	start := time.Now()
+	stopProcess(c, exited, syscall.SIGTERM, 5*time.Second)
+
+	assert.Less(t, int64(time.Since(start)), int64(2*time.Second))
+	assert.Equal(t, 0, c.ProcessState.ExitCode())
+}
+
+func TestStopProcessKill(t *testing.T) {
+	c, exited := startProcess(t, `trap "" TERM; while true; do sleep 0.05; done`)
+
+	start := time.Now()
+	stopProcess(c, exited, syscall.SIGTERM, 300*time.Millisecond)
+
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(300*time.Millisecond))
+	assert.Equal(t, syscall.SIGKILL, c.ProcessState.Sys().(syscall.WaitStatus).Signal())
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"SIGTERM", "TERM", "term", "15"} {
+		sig, err := parseSignal(name)
+		assert.NoError(t, err)
+		assert.Equal(t, syscall.SIGTERM, sig)
+	}
+
+	sig, err := parseSignal("SIGUSR1")
+	assert.NoError(t, err)
+	assert.Equal(t, syscall.SIGUSR1, sig)
+
+	_, err = parseSignal("SIGNOPE")
+	assert.Error(t, err)
+}
+
+func TestRestartPolicy(t *testing.T) {
+	assert.True(t, restartOnExit(restartAlways, 0))
+	assert.True(t, restartOnExit(restartAlways, 1))
+	assert.False(t, restartOnExit(restartOnFailure, 0))
+	assert.True(t, restartOnExit(restartOnFailure, 137))
+	assert.False(t, restartOnExit(restartNever, 1))
+
+	assert.NoError(t, checkRestartPolicy(restartOnFailure))
+	assert.Error(t, checkRestartPolicy("unless-stopped"))
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+func init() {
+	signals["USR1"] = syscall.SIGUSR1
+	signals["USR2"] = syscall.SIGUSR2
+	signals["WINCH"] = syscall.SIGWINCH
+	signals["CONT"] = syscall.SIGCONT
+	signals["TSTP"] = syscall.SIGTSTP
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/openware/kaigara/pkg/config"
+	"github.com/openware/kaigara/pkg/encryptor/keys"
+	"github.com/openware/kaigara/pkg/env"
+	"github.com/openware/kaigara/pkg/logger"
+	"github.com/openware/kaigara/pkg/metrics"
+	"github.com/openware/kaigara/pkg/snapshot"
+	"github.com/openware/kaigara/types"
+)
+
+var (
+	// snapshotKey is the key of the local snapshot, it's only set when KAIGARA_SNAPSHOT_PATH is
+	snapshotKey []byte
+	// fromSnapshot tells if the processes run with the secrets of the local snapshot
+	fromSnapshot bool
+)
+
+// loadSnapshotKey returns the key of the local snapshot from the key file or the inline key
+func loadSnapshotKey() ([]byte, error) {
+	if conf.SnapshotKeyFile != "" {
+		return keys.LoadFile(conf.SnapshotKeyFile, snapshot.KeySize)
+	}
+
+	if conf.SnapshotKey != "" {
+		return keys.Decode(conf.SnapshotKey, snapshot.KeySize)
+	}
+
+	return nil, fmt.Errorf("snapshot key is not set, use KAIGARA_SNAPSHOT_KEY or KAIGARA_SNAPSHOT_KEY_FILE")
+}
+
+// liveEnv builds the environment and files of the command from the secret storage and saves them as the local snapshot
+func liveEnv(ss types.Storage) (*config.Env, error) {
+	current := os.Environ()
+	stored, err := env.BuildStoredEnv(parseAppNames(), ss, current, parseScopes(), rules)
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshotKey != nil {
+		// The variables of the kaigara environment aren't saved, the ones of the environment it's loaded in are used
+		if err := snapshot.Save(conf.SnapshotPath, snapshotKey, stored); err != nil {
+			logger.Warn("failed to save local snapshot", logger.F("path", conf.SnapshotPath), logger.Err(err))
+		}
+	}
+
+	if fromSnapshot {
+		logger.Info("secret storage is reachable again, switching to the live secrets")
+		fromSnapshot = false
+		metrics.SnapshotInUse(false)
+	}
+
+	return env.MergeEnv(current, stored, rules), nil
+}
+
+// loadEnv builds the environment and files of the command from the secret storage,
+// or loads them from the local snapshot when the storage is unreachable
+func loadEnv(ss types.Storage) (*config.Env, error) {
+	envs, err := liveEnv(ss)
+	if err == nil || snapshotKey == nil || !errors.Is(err, env.ErrStorage) {
+		return envs, err
+	}
+
+	snap, snapErr := snapshot.Load(conf.SnapshotPath, snapshotKey, conf.SnapshotMaxAge)
+	if snapErr != nil {
+		return nil, fmt.Errorf("%s, and the local snapshot can't be used: %s", err, snapErr)
+	}
+
+	logger.Warn("starting process with the local snapshot", logger.F("saved_at", snap.SavedAt), logger.Err(err))
+	fromSnapshot = true
+	metrics.SnapshotInUse(true)
+
+	return env.MergeEnv(os.Environ(), snap.Env(), rules), nil
+}
+
+// notifyWhenStorageReachable tries to build the environment from the secret storage every pollInterval
+// and sends an event to outdated once it succeeds, until stop is closed
+func notifyWhenStorageReachable(ss types.Storage, outdated chan<- outdatedEvent, stop <-chan struct{}) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := buildEnv(ss); err != nil {
+			metrics.PollFailed()
+			continue
+		}
+
+		metrics.PollSucceeded()
+		outdated <- outdatedEvent{msg: "secret storage is reachable again"}
+		return
+	}
+}
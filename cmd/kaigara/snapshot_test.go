@@ -0,0 +1,142 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/snapshot"
+	"github.com/openware/kaigara/types"
+)
+
+// unreachableStorage fails the calls reaching the storage while it's down
+type unreachableStorage struct {
+	types.Storage
+	down int32
+}
+
+func (us *unreachableStorage) setDown(down bool) {
+	if down {
+		atomic.StoreInt32(&us.down, 1)
+	} else {
+		atomic.StoreInt32(&us.down, 0)
+	}
+}
+
+func (us *unreachableStorage) Read(appName, scope string) error {
+	if atomic.LoadInt32(&us.down) == 1 {
+		return errors.New("connection refused")
+	}
+
+	return us.Storage.Read(appName, scope)
+}
+
+func (us *unreachableStorage) GetLatestVersion(appName, scope string) (int64, error) {
+	if atomic.LoadInt32(&us.down) == 1 {
+		return 0, errors.New("connection refused")
+	}
+
+	return us.Storage.GetLatestVersion(appName, scope)
+}
+
+func withSnapshot(t *testing.T, maxAge time.Duration) func() {
+	conf.SnapshotPath = path.Join(t.TempDir(), "snapshot")
+	conf.SnapshotMaxAge = maxAge
+	snapshotKey = bytes.Repeat([]byte{1}, snapshot.KeySize)
+
+	return func() {
+		conf.SnapshotPath, snapshotKey, fromSnapshot = "", nil, false
+	}
+}
+
+func TestLoadEnvSnapshot(t *testing.T) {
+	defer withSnapshot(t, time.Hour)()
+	t.Setenv("FINEX_ENV", "production")
+
+	ss, writer := newReloadStorage(t, map[string]interface{}{"finex_host": "0.0.0.0"})
+	us := &unreachableStorage{Storage: ss}
+
+	live, err := loadEnv(us)
+	assert.NoError(t, err)
+	assert.False(t, fromSnapshot)
+
+	us.setDown(true)
+	envs, err := loadEnv(us)
+	assert.NoError(t, err)
+	assert.True(t, fromSnapshot)
+	assert.Equal(t, live, envs)
+
+	// The environment kaigara runs in isn't taken from the snapshot
+	t.Setenv("FINEX_ENV", "staging")
+	envs, err = loadEnv(us)
+	assert.NoError(t, err)
+	assert.Contains(t, envs.Vars, "FINEX_ENV=staging")
+	assert.NotContains(t, envs.Vars, "FINEX_ENV=production")
+	assert.Contains(t, envs.Vars, "FINEX_HOST=0.0.0.0")
+
+	us.setDown(false)
+	_, err = loadEnv(us)
+	assert.NoError(t, err)
+	assert.False(t, fromSnapshot)
+
+	// Invalid secrets aren't replaced by the snapshot
+	updateSecrets(t, writer, map[string]interface{}{"kfile_cert_path": "/etc/cert.pem"})
+	_, err = loadEnv(us)
+	assert.ErrorContains(t, err, "KFILE_CERT_CONTENT")
+}
+
+func TestLoadEnvSnapshotTooOld(t *testing.T) {
+	defer withSnapshot(t, time.Millisecond)()
+
+	ss, _ := newReloadStorage(t, map[string]interface{}{"finex_host": "0.0.0.0"})
+	us := &unreachableStorage{Storage: ss}
+
+	_, err := loadEnv(us)
+	assert.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	us.setDown(true)
+	_, err = loadEnv(us)
+	assert.ErrorContains(t, err, "connection refused, and the local snapshot can't be used")
+	assert.False(t, fromSnapshot)
+}
+
+func TestKaigaraRunSnapshotReconnect(t *testing.T) {
+	defer withSnapshot(t, time.Hour)()
+	defer withOnChange(t, "restart")()
+
+	ss, writer := newReloadStorage(t, map[string]interface{}{"finex_host": "0.0.0.0"})
+	us := &unreachableStorage{Storage: ss}
+
+	_, err := loadEnv(us)
+	assert.NoError(t, err)
+
+	us.setDown(true)
+	envs, err := loadEnv(us)
+	assert.NoError(t, err)
+	assert.True(t, fromSnapshot)
+
+	go func() {
+		// The secrets are unchanged once the storage is back, the process keeps running with the live ones
+		time.Sleep(200 * time.Millisecond)
+		us.setDown(false)
+		time.Sleep(300 * time.Millisecond)
+		updateSecrets(t, writer, map[string]interface{}{"finex_host": "127.0.0.1"})
+	}()
+
+	proc := newProcess("", "sh", []string{"-c", `trap "exit 0" TERM; while true; do sleep 0.05; done`})
+	start := time.Now()
+	reason, _, err := kaigaraRun(us, envs, []*process{proc})
+	assert.NoError(t, err)
+	assert.Equal(t, secretsUpdated, reason)
+	assert.False(t, fromSnapshot)
+	// It's restarted because of the update, not when switching to the live secrets
+	assert.Greater(t, time.Since(start), 500*time.Millisecond)
+}
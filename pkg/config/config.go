@@ -1,6 +1,10 @@
 package config
 
 import (
+	"os"
+	"time"
+
+	"github.com/openware/kaigara/pkg/logger"
 	"github.com/openware/kaigara/pkg/sql"
 	"github.com/openware/pkg/ika"
 )
@@ -17,13 +21,61 @@ type KaigaraConfig struct {
 	VaultToken string `yaml:"vault_token" env:"KAIGARA_VAULT_TOKEN" env-default:"changeme"`
 	VaultAddr  string `yaml:"vault_addr" env:"KAIGARA_VAULT_ADDR" env-default:"http://127.0.0.1:8200"`
 
-	EncryptMethod string `yaml:"encryption_method" env:"KAIGARA_ENCRYPTOR" env-default:"plaintext"`
-	AesKey        string `yaml:"aes_key" env:"KAIGARA_ENCRYPTOR_AES_KEY" env-default:"changemechangeme"`
+	EncryptMethod   string   `yaml:"encryption_method" env:"KAIGARA_ENCRYPTOR" env-default:"plaintext"`
+	EncryptedScopes []string `yaml:"encrypted_scopes" env:"KAIGARA_ENCRYPTED_SCOPES" env-default:"secret"`
+	AesKey          string   `yaml:"aes_key" env:"KAIGARA_ENCRYPTOR_AES_KEY" env-default:"changemechangeme"`
+	AesKeyFile      string   `yaml:"aes_key_file" env:"KAIGARA_ENCRYPTOR_AES_KEY_FILE"`
+
+	XChaChaKey     string `yaml:"xchacha_key" env:"KAIGARA_ENCRYPTOR_XCHACHA_KEY"`
+	XChaChaKeyFile string `yaml:"xchacha_key_file" env:"KAIGARA_ENCRYPTOR_XCHACHA_KEY_FILE"`
+
+	Passphrase     string `yaml:"passphrase" env:"KAIGARA_ENCRYPTOR_PASSPHRASE"`
+	PassphraseFile string `yaml:"passphrase_file" env:"KAIGARA_ENCRYPTOR_PASSPHRASE_FILE"`
+	Salt           string `yaml:"salt" env:"KAIGARA_ENCRYPTOR_SALT"`
+	Insecure       bool   `yaml:"encryptor_insecure" env:"KAIGARA_ENCRYPTOR_INSECURE" env-default:"false"`
+
+	ExecCommand    string        `yaml:"exec_command" env:"KAIGARA_ENCRYPTOR_EXEC_COMMAND"`
+	ExecPersistent bool          `yaml:"exec_persistent" env:"KAIGARA_ENCRYPTOR_EXEC_PERSISTENT" env-default:"false"`
+	ExecTimeout    time.Duration `yaml:"exec_timeout" env:"KAIGARA_ENCRYPTOR_EXEC_TIMEOUT" env-default:"10s"`
+
+	IntegrityKey     string `yaml:"integrity_key" env:"KAIGARA_INTEGRITY_KEY"`
+	IntegrityKeyFile string `yaml:"integrity_key_file" env:"KAIGARA_INTEGRITY_KEY_FILE"`
+	IntegrityMode    string `yaml:"integrity_mode" env:"KAIGARA_INTEGRITY_MODE" env-default:"warn"`
 
 	KubeConfig string `yaml:"kubeconfig" env:"KUBECONFIG"`
 
-	LogLevel int                `yaml:"log_level" env:"KAIGARA_LOG_LEVEL" env-default:"1"`
-	DBConfig sql.DatabaseConfig `yaml:"database"`
+	RestartSignal      string        `yaml:"restart_signal" env:"KAIGARA_RESTART_SIGNAL" env-default:"SIGTERM"`
+	RestartGracePeriod time.Duration `yaml:"restart_grace_period" env:"KAIGARA_RESTART_GRACE_PERIOD" env-default:"30s"`
+	SignalProcessGroup bool          `yaml:"signal_process_group" env:"KAIGARA_SIGNAL_PROCESS_GROUP" env-default:"false"`
+	Init               bool          `yaml:"init" env:"KAIGARA_INIT" env-default:"false"`
+	Subreaper          bool          `yaml:"subreaper" env:"KAIGARA_SUBREAPER" env-default:"false"`
+	Exec               bool          `yaml:"exec" env:"KAIGARA_EXEC" env-default:"false"`
+	OnChange           string        `yaml:"on_change" env:"KAIGARA_ON_CHANGE" env-default:"restart"`
+	KfileCleanup       bool          `yaml:"kfile_cleanup" env:"KAIGARA_KFILE_CLEANUP" env-default:"false"`
+	MetricsAddr        string        `yaml:"metrics_addr" env:"KAIGARA_METRICS_ADDR"`
+	Procfile           string        `yaml:"procfile" env:"KAIGARA_PROCFILE"`
+	ProcfileOnExit     string        `yaml:"procfile_on_exit" env:"KAIGARA_PROCFILE_ON_EXIT" env-default:"stop"`
+	RestartPolicy      string        `yaml:"restart_policy" env:"KAIGARA_RESTART_POLICY" env-default:"never"`
+	RestartBackoff     time.Duration `yaml:"restart_backoff" env:"KAIGARA_RESTART_BACKOFF" env-default:"1s"`
+	RestartBackoffMax  time.Duration `yaml:"restart_backoff_max" env:"KAIGARA_RESTART_BACKOFF_MAX" env-default:"1m"`
+	RestartLimit       int           `yaml:"restart_limit" env:"KAIGARA_RESTART_LIMIT" env-default:"10"`
+	RestartWindow      time.Duration `yaml:"restart_window" env:"KAIGARA_RESTART_WINDOW" env-default:"10m"`
+	RollbackPeriod     time.Duration `yaml:"rollback_period" env:"KAIGARA_ROLLBACK_PERIOD" env-default:"0s"`
+
+	EnvPrefixes   map[string]string `yaml:"env_prefixes" env:"KAIGARA_ENV_PREFIXES"`
+	EnvRenames    map[string]string `yaml:"env_renames" env:"KAIGARA_ENV_RENAMES"`
+	EnvCollision  string            `yaml:"env_collision" env:"KAIGARA_ENV_COLLISION" env-default:"app-wins"`
+	EnvPrecedence string            `yaml:"env_precedence" env:"KAIGARA_ENV_PRECEDENCE" env-default:"storage-over-env"`
+
+	SnapshotPath    string        `yaml:"snapshot_path" env:"KAIGARA_SNAPSHOT_PATH"`
+	SnapshotKey     string        `yaml:"snapshot_key" env:"KAIGARA_SNAPSHOT_KEY"`
+	SnapshotKeyFile string        `yaml:"snapshot_key_file" env:"KAIGARA_SNAPSHOT_KEY_FILE"`
+	SnapshotMaxAge  time.Duration `yaml:"snapshot_max_age" env:"KAIGARA_SNAPSHOT_MAX_AGE" env-default:"24h"`
+
+	LogLevel           string             `yaml:"log_level" env:"KAIGARA_LOG_LEVEL" env-default:"info"`
+	LogFormat          string             `yaml:"log_format" env:"KAIGARA_LOG_FORMAT" env-default:"text"`
+	LogRedactMinLength int                `yaml:"log_redact_min_length" env:"KAIGARA_LOG_REDACT_MIN_LENGTH" env-default:"6"`
+	DBConfig           sql.DatabaseConfig `yaml:"database"`
 }
 
 // Config is the interface definition of generic config storage
@@ -35,12 +87,35 @@ type Config interface {
 type Env struct {
 	Vars  []string
 	Files map[string]*File
+	// Locked are the names of the stored variables which can't be overridden by the environment of kaigara
+	Locked []string
 }
 
 // File contains path and content of a file fetched from env by Kaigara
 type File struct {
 	Path    string
 	Content string
+	// Mode is the octal permission of the file, Owner is its user[:group] and Encoding the encoding of Content
+	Mode     string
+	Owner    string
+	Encoding string
+}
+
+// NewLogger returns the logger configured with the log level and format, name prefixes its text lines
+func NewLogger(conf *KaigaraConfig, name string) (*logger.Logger, error) {
+	level, err := logger.ParseLevel(conf.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := logger.ParseFormat(conf.LogFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.SetMinRedactLength(conf.LogRedactMinLength)
+
+	return logger.New(os.Stderr, name, level, format), nil
 }
 
 func NewKaigaraConfig() (*KaigaraConfig, error) {
@@ -7,11 +7,14 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
 )
 
 // AESEncryptor implements Encryptor interface by using AES
 type AESEncryptor struct {
-	key []byte
+	key   []byte
+	keyID string
 }
 
 // NewAESEncryptor instantiate an in memory encryption service
@@ -21,7 +24,8 @@ func NewAESEncryptor(key []byte) (*AESEncryptor, error) {
 	}
 
 	return &AESEncryptor{
-		key: key,
+		key:   key,
+		keyID: envelope.KeyID(key),
 	}, nil
 }
 
@@ -42,12 +46,18 @@ func (ae *AESEncryptor) Encrypt(plaintext, appName string) (string, error) {
 		return "", err
 	}
 
-	return base64.URLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+	payload := base64.URLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(plaintext), nil))
+	return envelope.Seal(envelope.AlgAES, ae.keyID, payload), nil
 }
 
 // Decrypt the given ciphertext and return the plaintext or an error
 func (ae *AESEncryptor) Decrypt(ciphertext, appName string) (string, error) {
-	encryptData, err := base64.URLEncoding.DecodeString(ciphertext)
+	payload, err := envelope.Open(ciphertext, envelope.AlgAES, ae.keyID)
+	if err != nil {
+		return "", err
+	}
+
+	encryptData, err := base64.URLEncoding.DecodeString(payload)
 	if err != nil {
 		return "", err
 	}
@@ -1,10 +1,13 @@
 package aes
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 	"gotest.tools/assert"
+
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
 )
 
 func TestAESEncryptorWrongKey(t *testing.T) {
@@ -18,8 +21,30 @@ func TestAESEncryptor(t *testing.T) {
 
 	cipher, err := s.Encrypt("bonjour", "")
 	require.NoError(t, err)
+	assert.Assert(t, strings.HasPrefix(cipher, "kgr:v1:aes:"))
 
 	plain, err := s.Decrypt(cipher, "")
 	require.NoError(t, err)
 	assert.Equal(t, "bonjour", plain)
 }
+
+func TestAESEncryptorLegacy(t *testing.T) {
+	s, err := NewAESEncryptor([]byte("1234567890123456"))
+	require.NoError(t, err)
+
+	cipher, err := s.Encrypt("bonjour", "")
+	require.NoError(t, err)
+
+	env, ok := envelope.Parse(cipher)
+	require.True(t, ok)
+
+	plain, err := s.Decrypt(env.Payload, "")
+	require.NoError(t, err)
+	assert.Equal(t, "bonjour", plain)
+
+	other, err := NewAESEncryptor([]byte("6543210987654321"))
+	require.NoError(t, err)
+
+	_, err = other.Decrypt(cipher, "")
+	require.Error(t, err)
+}
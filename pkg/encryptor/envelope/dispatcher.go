@@ -0,0 +1,130 @@
+package envelope
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/openware/kaigara/pkg/encryptor/types"
+)
+
+// Dispatcher implements Encryptor interface by encrypting with the primary encryptor
+// and decrypting with the encryptor named in the value envelope
+type Dispatcher struct {
+	alg        string
+	primary    types.Encryptor
+	encryptors map[string]types.Encryptor
+}
+
+// NewDispatcher instantiate a dispatcher encrypting new values with the primary encryptor named alg
+func NewDispatcher(alg string, primary types.Encryptor) *Dispatcher {
+	return &Dispatcher{
+		alg:     alg,
+		primary: primary,
+		encryptors: map[string]types.Encryptor{
+			alg: primary,
+		},
+	}
+}
+
+// Register adds an encryptor used to decrypt values produced by the alg encryptor,
+// plaintext values are refused anyway unless the primary encryptor is plaintext
+func (d *Dispatcher) Register(alg string, encryptor types.Encryptor) {
+	if alg == d.alg {
+		return
+	}
+
+	d.encryptors[alg] = encryptor
+}
+
+// Encrypt the plaintext argument with the primary encryptor
+func (d *Dispatcher) Encrypt(plaintext, appName string) (string, error) {
+	return d.primary.Encrypt(plaintext, appName)
+}
+
+// Decrypt the given ciphertext with the encryptor that produced it.
+// Legacy values without envelope are decrypted by the primary encryptor,
+// except for Vault transit ciphertexts which are recognized by their own prefix.
+func (d *Dispatcher) Decrypt(ciphertext, appName string) (string, error) {
+	encryptor, err := d.encryptorFor(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return encryptor.Decrypt(ciphertext, appName)
+}
+
+// EncryptBatch encrypts the plaintexts with the primary encryptor, in a single call if it supports batches
+func (d *Dispatcher) EncryptBatch(plaintexts []string, appName string) ([]string, error) {
+	return types.EncryptAll(d.primary, plaintexts, appName)
+}
+
+// DecryptBatch decrypts the ciphertexts grouped by the encryptor that produced them, preserving their order
+func (d *Dispatcher) DecryptBatch(ciphertexts []string, appName string) ([]string, error) {
+	groups := map[types.Encryptor][]int{}
+	var order []types.Encryptor
+	for i, ciphertext := range ciphertexts {
+		encryptor, err := d.encryptorFor(ciphertext)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := groups[encryptor]; !ok {
+			order = append(order, encryptor)
+		}
+		groups[encryptor] = append(groups[encryptor], i)
+	}
+
+	plaintexts := make([]string, len(ciphertexts))
+	for _, encryptor := range order {
+		indexes := groups[encryptor]
+		group := make([]string, len(indexes))
+		for j, i := range indexes {
+			group[j] = ciphertexts[i]
+		}
+
+		decrypted, err := types.DecryptAll(encryptor, group, appName)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range indexes {
+			plaintexts[i] = decrypted[j]
+		}
+	}
+
+	return plaintexts, nil
+}
+
+// Close closes the encryptors holding resources, like the process of a persistent exec encryptor
+func (d *Dispatcher) Close() error {
+	var err error
+	for _, encryptor := range d.encryptors {
+		if closer, ok := encryptor.(io.Closer); ok {
+			if closeErr := closer.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	}
+
+	return err
+}
+
+// encryptorFor returns the encryptor able to decrypt the ciphertext
+func (d *Dispatcher) encryptorFor(ciphertext string) (types.Encryptor, error) {
+	alg := d.alg
+	if env, ok := Parse(ciphertext); ok {
+		alg = env.Alg
+	} else if strings.HasPrefix(ciphertext, "vault:v") && d.encryptors[AlgTransit] != nil {
+		alg = AlgTransit
+	}
+
+	if alg == AlgPlaintext && d.alg != AlgPlaintext {
+		return nil, fmt.Errorf("plaintext value is refused since values are encrypted with '%s' encryptor", d.alg)
+	}
+
+	encryptor, ok := d.encryptors[alg]
+	if !ok {
+		return nil, fmt.Errorf("value is encrypted with '%s' encryptor, which is not configured (current encryptor is '%s')", alg, d.alg)
+	}
+
+	return encryptor, nil
+}
@@ -0,0 +1,84 @@
+// Package envelope implements the self-describing format of the values produced by the encryptors.
+//
+// Every encrypted value is stored as:
+//
+//	kgr:v1:<alg>:<keyid>:<payload>
+//
+// where alg is the name of the encryptor (as in KAIGARA_ENCRYPTOR), keyid identifies the key used by this encryptor
+// (it may be empty) and payload is the encryptor specific ciphertext, which may contain colons.
+package envelope
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Prefix marks values wrapped into the v1 envelope
+const Prefix = "kgr:v1:"
+
+// Algorithm names used in envelopes, they match the encryptor names in the configuration
+const (
+	AlgPlaintext = "plaintext"
+	AlgAES       = "aes"
+	AlgXChaCha   = "xchacha20poly1305"
+	AlgTransit   = "transit"
+	AlgExec      = "exec"
+)
+
+// Envelope is a parsed encrypted value
+type Envelope struct {
+	Alg     string
+	KeyID   string
+	Payload string
+}
+
+// Seal wraps a payload produced by the alg encryptor into an envelope string
+func Seal(alg, keyID, payload string) string {
+	return Prefix + alg + ":" + keyID + ":" + payload
+}
+
+// Parse splits an envelope string, the second returned value is false for legacy values without envelope
+func Parse(value string) (*Envelope, bool) {
+	if !strings.HasPrefix(value, Prefix) {
+		return nil, false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, Prefix), ":", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return nil, false
+	}
+
+	return &Envelope{
+		Alg:     parts[0],
+		KeyID:   parts[1],
+		Payload: parts[2],
+	}, true
+}
+
+// Open returns the payload of a value produced by the alg encryptor with the keyID key.
+// Legacy values without envelope are returned as is, so they can still be decrypted.
+// An empty keyID skips the key check.
+func Open(value, alg, keyID string) (string, error) {
+	env, ok := Parse(value)
+	if !ok {
+		return value, nil
+	}
+
+	if env.Alg != alg {
+		return "", fmt.Errorf("value is encrypted with '%s' encryptor, not '%s'", env.Alg, alg)
+	}
+
+	if keyID != "" && env.KeyID != "" && env.KeyID != keyID {
+		return "", fmt.Errorf("value is encrypted with '%s' key '%s', configured key is '%s'", alg, env.KeyID, keyID)
+	}
+
+	return env.Payload, nil
+}
+
+// KeyID returns a short fingerprint of a raw key, which is safe to store next to the ciphertext
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
@@ -0,0 +1,130 @@
+package envelope
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+func TestSealParse(t *testing.T) {
+	value := Seal(AlgTransit, "opendax_kaigara_finex", "vault:v1:abcdef")
+	assert.Equal(t, "kgr:v1:transit:opendax_kaigara_finex:vault:v1:abcdef", value)
+
+	env, ok := Parse(value)
+	require.True(t, ok)
+	assert.Equal(t, AlgTransit, env.Alg)
+	assert.Equal(t, "opendax_kaigara_finex", env.KeyID)
+	assert.Equal(t, "vault:v1:abcdef", env.Payload)
+
+	env, ok = Parse(Seal(AlgPlaintext, "", ""))
+	require.True(t, ok)
+	assert.Equal(t, "", env.KeyID)
+	assert.Equal(t, "", env.Payload)
+
+	for _, legacy := range []string{"bonjour", "vault:v1:abcdef", "kgr:v1:", "kgr:v1:aes"} {
+		_, ok := Parse(legacy)
+		assert.Equal(t, false, ok)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	payload, err := Open(Seal(AlgAES, "0a0b0c0d", "payload"), AlgAES, "0a0b0c0d")
+	require.NoError(t, err)
+	assert.Equal(t, "payload", payload)
+
+	payload, err = Open("legacy", AlgAES, "0a0b0c0d")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", payload)
+
+	_, err = Open(Seal(AlgAES, "0a0b0c0d", "payload"), AlgXChaCha, "0a0b0c0d")
+	require.Error(t, err)
+
+	_, err = Open(Seal(AlgAES, "0a0b0c0d", "payload"), AlgAES, "01020304")
+	require.Error(t, err)
+}
+
+type fakeEncryptor struct {
+	alg string
+}
+
+func (fe *fakeEncryptor) Encrypt(plaintext, appName string) (string, error) {
+	return Seal(fe.alg, "", plaintext), nil
+}
+
+func (fe *fakeEncryptor) Decrypt(ciphertext, appName string) (string, error) {
+	return Open(ciphertext, fe.alg, "")
+}
+
+// closingEncryptor records if it was closed
+type closingEncryptor struct {
+	fakeEncryptor
+	closed bool
+}
+
+func (ce *closingEncryptor) Close() error {
+	ce.closed = true
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	d := NewDispatcher(AlgAES, &fakeEncryptor{alg: AlgAES})
+	d.Register(AlgXChaCha, &fakeEncryptor{alg: AlgXChaCha})
+
+	cipher, err := d.Encrypt("bonjour", "finex")
+	require.NoError(t, err)
+	assert.Equal(t, Seal(AlgAES, "", "bonjour"), cipher)
+
+	for _, value := range []string{cipher, Seal(AlgXChaCha, "", "bonjour"), "bonjour"} {
+		plain, err := d.Decrypt(value, "finex")
+		require.NoError(t, err)
+		assert.Equal(t, "bonjour", plain)
+	}
+
+	_, err = d.Decrypt(Seal(AlgTransit, "finex", "vault:v1:abcdef"), "finex")
+	require.Error(t, err)
+}
+
+func TestDispatcherRefusesPlaintext(t *testing.T) {
+	d := NewDispatcher(AlgAES, &fakeEncryptor{alg: AlgAES})
+	d.Register(AlgPlaintext, &fakeEncryptor{alg: AlgPlaintext})
+
+	_, err := d.Decrypt(Seal(AlgPlaintext, "", "injected"), "finex")
+	assert.ErrorContains(t, err, "plaintext value is refused")
+
+	d = NewDispatcher(AlgPlaintext, &fakeEncryptor{alg: AlgPlaintext})
+	plain, err := d.Decrypt(Seal(AlgPlaintext, "", "bonjour"), "finex")
+	require.NoError(t, err)
+	assert.Equal(t, "bonjour", plain)
+}
+
+func TestDispatcherDecryptBatch(t *testing.T) {
+	d := NewDispatcher(AlgAES, &fakeEncryptor{alg: AlgAES})
+	d.Register(AlgXChaCha, &fakeEncryptor{alg: AlgXChaCha})
+
+	ciphertexts := []string{
+		Seal(AlgAES, "", "one"),
+		Seal(AlgXChaCha, "", "two"),
+		"three",
+		Seal(AlgXChaCha, "", "four"),
+	}
+
+	plaintexts, err := d.DecryptBatch(ciphertexts, "finex")
+	require.NoError(t, err)
+	assert.DeepEqual(t, []string{"one", "two", "three", "four"}, plaintexts)
+
+	_, err = d.DecryptBatch([]string{Seal(AlgTransit, "finex", "vault:v1:abcdef")}, "finex")
+	require.Error(t, err)
+}
+
+func TestDispatcherClose(t *testing.T) {
+	primary := &closingEncryptor{fakeEncryptor: fakeEncryptor{alg: AlgExec}}
+	fallback := &closingEncryptor{fakeEncryptor: fakeEncryptor{alg: AlgExec}}
+	d := NewDispatcher(AlgExec, primary)
+	d.Register(AlgAES, &fakeEncryptor{alg: AlgAES})
+	d.Register(AlgXChaCha, fallback)
+
+	require.NoError(t, d.Close())
+	assert.Equal(t, true, primary.closed)
+	assert.Equal(t, true, fallback.closed)
+}
@@ -0,0 +1,351 @@
+// Package exec implements the Encryptor interface by delegating encryption to an external command,
+// which lets operators plug a KMS, an HSM or any in-house tool without changes to kaigara.
+//
+// Kaigara writes one JSON request per line on the standard input of the command:
+//
+//	{"op": "encrypt", "key": "<key name>", "values": ["value1", "value2"]}
+//
+// op is either "encrypt" or "decrypt", key is the name of the key of the app (as used by the transit encryptor)
+// and values holds one or more UTF-8 strings to process. The command must answer with one JSON line on its standard output:
+//
+//	{"values": ["result1", "result2"]}
+//
+// holding exactly one result per value, in the same order, or report a failure with:
+//
+//	{"error": "message"}
+//
+// In one-shot mode the command is started for every request and its standard input is closed after the request.
+// In persistent mode a single process is kept running and receives the requests one after the other.
+// Anything written by the command on its standard error is forwarded to kaigara's standard error.
+//
+// Ciphertexts returned by the command are opaque to kaigara, they are wrapped into an envelope with the exec algorithm.
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	osexec "os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
+)
+
+// DefaultTimeout is the maximum duration of a request when no timeout is configured
+const DefaultTimeout = 10 * time.Second
+
+// Request is a message sent to the external command
+type Request struct {
+	Op     string   `json:"op"`
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+}
+
+// Response is a message received from the external command
+type Response struct {
+	Values []string `json:"values,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// ExecEncryptor implements Encryptor interface by calling an external command
+type ExecEncryptor struct {
+	command    []string
+	persistent bool
+	timeout    time.Duration
+
+	mutex   sync.Mutex
+	process *process
+}
+
+type process struct {
+	cmd    *osexec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// NewExecEncryptor instantiate an encryptor running the given command with its arguments
+func NewExecEncryptor(command []string, persistent bool, timeout time.Duration) (*ExecEncryptor, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, fmt.Errorf("exec encryptor command is empty")
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &ExecEncryptor{
+		command:    command,
+		persistent: persistent,
+		timeout:    timeout,
+	}, nil
+}
+
+// SplitCommand splits a command line into its arguments like a POSIX shell does, without expansions:
+// arguments are separated by blanks, which are kept inside single or double quotes or after a backslash
+func SplitCommand(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			// In double quotes the backslash only escapes the characters the shell gives a meaning to
+			if quote == '"' && !strings.ContainsRune(`"\$`+"`", r) {
+				arg.WriteRune('\\')
+			}
+			arg.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash in command")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
+
+// Encrypt the plaintext argument and return a ciphertext string or an error
+func (ee *ExecEncryptor) Encrypt(plaintext, appName string) (string, error) {
+	ciphertexts, err := ee.EncryptBatch([]string{plaintext}, appName)
+	if err != nil {
+		return "", err
+	}
+
+	return ciphertexts[0], nil
+}
+
+// Decrypt the ciphertext argument and return a plaintext string or an error
+func (ee *ExecEncryptor) Decrypt(ciphertext, appName string) (string, error) {
+	plaintexts, err := ee.DecryptBatch([]string{ciphertext}, appName)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintexts[0], nil
+}
+
+// EncryptBatch encrypts all the plaintexts with a single request to the command
+func (ee *ExecEncryptor) EncryptBatch(plaintexts []string, appName string) ([]string, error) {
+	payloads, err := ee.call("encrypt", appName, plaintexts)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertexts := make([]string, len(payloads))
+	for i, payload := range payloads {
+		ciphertexts[i] = envelope.Seal(envelope.AlgExec, appName, payload)
+	}
+
+	return ciphertexts, nil
+}
+
+// DecryptBatch decrypts all the ciphertexts with a single request to the command
+func (ee *ExecEncryptor) DecryptBatch(ciphertexts []string, appName string) ([]string, error) {
+	payloads := make([]string, len(ciphertexts))
+	for i, ciphertext := range ciphertexts {
+		payload, err := envelope.Open(ciphertext, envelope.AlgExec, appName)
+		if err != nil {
+			return nil, err
+		}
+		payloads[i] = payload
+	}
+
+	return ee.call("decrypt", appName, payloads)
+}
+
+// Close stops the process started in persistent mode
+func (ee *ExecEncryptor) Close() error {
+	ee.mutex.Lock()
+	defer ee.mutex.Unlock()
+
+	return ee.stop()
+}
+
+func (ee *ExecEncryptor) call(op, key string, values []string) ([]string, error) {
+	if len(values) == 0 {
+		return []string{}, nil
+	}
+
+	line, err := json.Marshal(&Request{Op: op, Key: key, Values: values})
+	if err != nil {
+		return nil, err
+	}
+	line = append(line, '\n')
+
+	ee.mutex.Lock()
+	defer ee.mutex.Unlock()
+
+	var out []byte
+	if ee.persistent {
+		out, err = ee.roundTrip(line)
+	} else {
+		out, err = ee.runOnce(line)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("exec encryptor failed to %s: %s", op, err)
+	}
+
+	var res Response
+	if err := json.Unmarshal(out, &res); err != nil {
+		return nil, fmt.Errorf("exec encryptor returned an invalid response to %s: %s", op, err)
+	}
+
+	if res.Error != "" {
+		return nil, fmt.Errorf("exec encryptor failed to %s: %s", op, res.Error)
+	}
+
+	if len(res.Values) != len(values) {
+		return nil, fmt.Errorf("exec encryptor returned %d values to %s, expected %d", len(res.Values), op, len(values))
+	}
+
+	return res.Values, nil
+}
+
+// runOnce starts the command, sends the request and returns the first line of its output
+func (ee *ExecEncryptor) runOnce(line []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ee.timeout)
+	defer cancel()
+
+	cmd := osexec.CommandContext(ctx, ee.command[0], ee.command[1:]...)
+	cmd.Stdin = bytes.NewReader(line)
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("no response after %s", ee.timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if i := bytes.IndexByte(out, '\n'); i >= 0 {
+		out = out[:i]
+	}
+
+	return out, nil
+}
+
+// roundTrip sends the request to the persistent process, starting it if needed, and reads one line of response.
+// The process is stopped on any error so that the next request starts from a clean state.
+func (ee *ExecEncryptor) roundTrip(line []byte) ([]byte, error) {
+	if ee.process == nil {
+		if err := ee.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	type result struct {
+		out []byte
+		err error
+	}
+	done := make(chan result, 1)
+	proc := ee.process
+
+	go func() {
+		if _, err := proc.stdin.Write(line); err != nil {
+			done <- result{err: err}
+			return
+		}
+		out, err := proc.stdout.ReadBytes('\n')
+		done <- result{out: bytes.TrimRight(out, "\n"), err: err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			ee.stop()
+			return nil, res.err
+		}
+		return res.out, nil
+	case <-time.After(ee.timeout):
+		ee.stop()
+		return nil, fmt.Errorf("no response after %s", ee.timeout)
+	}
+}
+
+func (ee *ExecEncryptor) start() error {
+	cmd := osexec.Command(ee.command[0], ee.command[1:]...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	ee.process = &process{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}
+
+	return nil
+}
+
+func (ee *ExecEncryptor) stop() error {
+	if ee.process == nil {
+		return nil
+	}
+
+	proc := ee.process
+	ee.process = nil
+
+	proc.stdin.Close()
+	proc.cmd.Process.Kill()
+
+	// The process is killed, the error only reports how it exited
+	proc.cmd.Wait()
+
+	return nil
+}
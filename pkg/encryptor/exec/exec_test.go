@@ -0,0 +1,131 @@
+package exec
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
+)
+
+// TestHelperProcess is not a real test, it is the fake encryptor started by the other tests:
+// it base64 encodes values to encrypt them and fails for the key named "broken"
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("KAIGARA_EXEC_HELPER") != "1" {
+		return
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req Request
+		var res Response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			res.Error = err.Error()
+		}
+
+		for _, value := range req.Values {
+			switch {
+			case req.Key == "broken":
+				res.Error = "key is broken"
+			case req.Op == "encrypt":
+				res.Values = append(res.Values, base64.StdEncoding.EncodeToString([]byte(value)))
+			case req.Op == "decrypt":
+				plain, err := base64.StdEncoding.DecodeString(value)
+				if err != nil {
+					res.Error = err.Error()
+				}
+				res.Values = append(res.Values, string(plain))
+			}
+		}
+
+		out, _ := json.Marshal(&res)
+		fmt.Println(string(out))
+	}
+	os.Exit(0)
+}
+
+func newHelperEncryptor(t *testing.T, persistent bool) *ExecEncryptor {
+	t.Setenv("KAIGARA_EXEC_HELPER", "1")
+
+	ee, err := NewExecEncryptor([]string{os.Args[0], "-test.run=TestHelperProcess"}, persistent, 5*time.Second)
+	require.NoError(t, err)
+	t.Cleanup(func() { ee.Close() })
+
+	return ee
+}
+
+func TestExecEncryptor(t *testing.T) {
+	for _, persistent := range []bool{false, true} {
+		ee := newHelperEncryptor(t, persistent)
+
+		cipher, err := ee.Encrypt("bonjour", "finex")
+		require.NoError(t, err)
+		assert.Equal(t, envelope.Seal(envelope.AlgExec, "finex", "Ym9uam91cg=="), cipher)
+
+		plain, err := ee.Decrypt(cipher, "finex")
+		require.NoError(t, err)
+		assert.Equal(t, "bonjour", plain)
+
+		_, err = ee.Decrypt(cipher, "peatio")
+		require.Error(t, err)
+
+		_, err = ee.Encrypt("bonjour", "broken")
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "key is broken")
+	}
+}
+
+func TestExecEncryptorBatch(t *testing.T) {
+	ee := newHelperEncryptor(t, true)
+
+	plaintexts := []string{"one", "two", "", "quatre: 4"}
+	ciphertexts, err := ee.EncryptBatch(plaintexts, "finex")
+	require.NoError(t, err)
+	assert.Equal(t, len(plaintexts), len(ciphertexts))
+
+	decrypted, err := ee.DecryptBatch(ciphertexts, "finex")
+	require.NoError(t, err)
+	assert.DeepEqual(t, plaintexts, decrypted)
+}
+
+func TestExecEncryptorFailures(t *testing.T) {
+	_, err := NewExecEncryptor(nil, false, 0)
+	require.Error(t, err)
+
+	ee, err := NewExecEncryptor([]string{"/nonexistent/encryptor"}, false, 0)
+	require.NoError(t, err)
+	_, err = ee.Encrypt("bonjour", "finex")
+	require.Error(t, err)
+
+	ee, err = NewExecEncryptor([]string{"sleep", "10"}, true, 100*time.Millisecond)
+	require.NoError(t, err)
+	_, err = ee.Encrypt("bonjour", "finex")
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "no response after")
+}
+
+func TestSplitCommand(t *testing.T) {
+	for line, expected := range map[string][]string{
+		"/usr/local/bin/kms-encryptor --region eu-west-1":        {"/usr/local/bin/kms-encryptor", "--region", "eu-west-1"},
+		`  encryptor   --label 'kaigara secrets'  `:              {"encryptor", "--label", "kaigara secrets"},
+		`encryptor --label "it's \"quoted\"" --path \$HOME\ dir`: {"encryptor", "--label", `it's "quoted"`, "--path", "$HOME dir"},
+		`encryptor "C:\dir" ''`:                                  {"encryptor", `C:\dir`, ""},
+	} {
+		args, err := SplitCommand(line)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, expected, args)
+	}
+
+	_, err := SplitCommand(`encryptor --label 'kaigara`)
+	assert.ErrorContains(t, err, "unterminated ' quote")
+
+	_, err = SplitCommand(`encryptor \`)
+	assert.ErrorContains(t, err, "trailing backslash")
+}
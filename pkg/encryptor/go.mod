@@ -5,6 +5,7 @@ go 1.17
 require (
 	github.com/hashicorp/vault/api v1.3.1
 	github.com/stretchr/testify v1.7.1
+	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97
 	gotest.tools v2.2.0+incompatible
 )
 
@@ -48,7 +49,6 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/ryanuber/go-glob v1.0.0 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
-	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97 // indirect
 	golang.org/x/net v0.0.0-20210226172049-e18ecbb05110 // indirect
 	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
 	golang.org/x/text v0.3.3 // indirect
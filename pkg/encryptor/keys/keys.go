@@ -0,0 +1,144 @@
+package keys
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// MinSaltSize is the minimum length in bytes of the salt used to derive keys from a passphrase
+const MinSaltSize = 16
+
+// scrypt parameters recommended for interactive logins, the derivation runs once at startup
+const (
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+// Decode parses a hex or base64 encoded key and makes sure its length is one of the given sizes in bytes.
+// The encoding can be forced with a "hex:" or "base64:" prefix, otherwise it is guessed from the input.
+func Decode(encoded string, sizes ...int) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, fmt.Errorf("key is empty")
+	}
+
+	hexSized := false
+	for _, size := range sizes {
+		hexSized = hexSized || len(encoded) == hex.EncodedLen(size)
+	}
+
+	key, err := decode(encoded, hexSized && isHex(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key: %s", err)
+	}
+
+	for _, size := range sizes {
+		if len(key) == size {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("key length should be exactly %s bytes, actual length: %d", joinSizes(sizes), len(key))
+}
+
+// LoadFile reads a hex or base64 encoded key from a file, see Decode
+func LoadFile(path string, sizes ...int) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %s", err)
+	}
+
+	return Decode(string(data), sizes...)
+}
+
+// DecodeSalt parses a hex or base64 encoded salt of at least MinSaltSize bytes
+func DecodeSalt(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, fmt.Errorf("salt is empty")
+	}
+
+	salt, err := decode(encoded, len(encoded)%2 == 0 && isHex(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode salt: %s", err)
+	}
+
+	if len(salt) < MinSaltSize {
+		return nil, fmt.Errorf("salt should be at least %d bytes long, actual length: %d", MinSaltSize, len(salt))
+	}
+
+	return salt, nil
+}
+
+// Derive computes a size bytes key from a passphrase with scrypt.
+// The purpose, e.g. the encryptor name, is mixed into the salt so that each encryptor gets its own key.
+func Derive(passphrase string, salt []byte, purpose string, size int) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase is empty")
+	}
+
+	if len(salt) < MinSaltSize {
+		return nil, fmt.Errorf("salt should be at least %d bytes long, actual length: %d", MinSaltSize, len(salt))
+	}
+
+	s := make([]byte, 0, len(salt)+1+len(purpose))
+	s = append(s, salt...)
+	s = append(s, 0)
+	s = append(s, purpose...)
+
+	return scrypt.Key([]byte(passphrase), s, scryptN, scryptR, scryptP, size)
+}
+
+// decode decodes a prefixed value, or a hex value if guessHex is set, or a base64 value
+func decode(encoded string, guessHex bool) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(encoded, "hex:"):
+		return hex.DecodeString(strings.TrimPrefix(encoded, "hex:"))
+	case strings.HasPrefix(encoded, "base64:"):
+		return decodeBase64(strings.TrimPrefix(encoded, "base64:"))
+	case guessHex:
+		return hex.DecodeString(encoded)
+	default:
+		return decodeBase64(encoded)
+	}
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func joinSizes(sizes []int) string {
+	strs := make([]string, len(sizes))
+	for i, size := range sizes {
+		strs[i] = strconv.Itoa(size)
+	}
+
+	return strings.Join(strs, " or ")
+}
+
+func decodeBase64(encoded string) ([]byte, error) {
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.URLEncoding,
+		base64.RawStdEncoding,
+		base64.RawURLEncoding,
+	}
+
+	var err error
+	for _, encoding := range encodings {
+		var key []byte
+		if key, err = encoding.DecodeString(encoded); err == nil {
+			return key, nil
+		}
+	}
+
+	return nil, err
+}
@@ -0,0 +1,98 @@
+package keys
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+const hexKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+const b64Key = "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+
+func TestDecode(t *testing.T) {
+	for _, encoded := range []string{hexKey, "hex:" + hexKey, b64Key, "base64:" + b64Key, " " + b64Key + "\n"} {
+		key, err := Decode(encoded, 32)
+		require.NoError(t, err)
+		assert.Equal(t, 32, len(key))
+		assert.Equal(t, byte(0x1f), key[31])
+	}
+}
+
+func TestDecodeWrongKey(t *testing.T) {
+	_, err := Decode("", 32)
+	require.Error(t, err)
+
+	_, err = Decode("changemechangemechangemechangeme", 32)
+	require.Error(t, err)
+
+	_, err = Decode("hex:"+hexKey, 16)
+	require.Error(t, err)
+}
+
+func TestLoadFile(t *testing.T) {
+	keyPath := path.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(keyPath, []byte(b64Key+"\n"), 0600))
+
+	key, err := LoadFile(keyPath, 32)
+	require.NoError(t, err)
+	assert.Equal(t, 32, len(key))
+
+	_, err = LoadFile(path.Join(t.TempDir(), "missing"), 32)
+	require.Error(t, err)
+}
+
+func TestDecodeSizes(t *testing.T) {
+	key, err := Decode("hex:000102030405060708090a0b0c0d0e0f", 16, 24, 32)
+	require.NoError(t, err)
+	assert.Equal(t, 16, len(key))
+
+	// 32 characters which are not hex are decoded as base64
+	key, err = Decode("AAECAwQFBgcICQoLDA0ODxAREhMUFRYX", 16, 24, 32)
+	require.NoError(t, err)
+	assert.Equal(t, 24, len(key))
+
+	_, err = Decode("base64:AAECAwQFBgcICQ==", 16, 24, 32)
+	assert.ErrorContains(t, err, "16 or 24 or 32 bytes")
+}
+
+func TestDecodeSalt(t *testing.T) {
+	salt, err := DecodeSalt("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+	assert.Equal(t, 16, len(salt))
+
+	salt, err = DecodeSalt(b64Key)
+	require.NoError(t, err)
+	assert.Equal(t, 32, len(salt))
+
+	_, err = DecodeSalt("hex:0001020304")
+	require.Error(t, err)
+
+	_, err = DecodeSalt("")
+	require.Error(t, err)
+}
+
+func TestDerive(t *testing.T) {
+	salt, err := DecodeSalt(hexKey)
+	require.NoError(t, err)
+
+	aesKey, err := Derive("correct horse battery staple", salt, "aes", 32)
+	require.NoError(t, err)
+	assert.Equal(t, 32, len(aesKey))
+
+	again, err := Derive("correct horse battery staple", salt, "aes", 32)
+	require.NoError(t, err)
+	assert.DeepEqual(t, aesKey, again)
+
+	xchachaKey, err := Derive("correct horse battery staple", salt, "xchacha20poly1305", 32)
+	require.NoError(t, err)
+	assert.Assert(t, string(aesKey) != string(xchachaKey))
+
+	_, err = Derive("", salt, "aes", 32)
+	require.Error(t, err)
+
+	_, err = Derive("correct horse battery staple", salt[:8], "aes", 32)
+	require.Error(t, err)
+}
@@ -2,6 +2,8 @@ package plaintext
 
 import (
 	"fmt"
+
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
 )
 
 // PlaintextEncryptor implements Encryptor interface
@@ -12,20 +14,20 @@ func NewPlaintextEncryptor() *PlaintextEncryptor {
 	return &PlaintextEncryptor{}
 }
 
-// Encrypt implements plaintext encryption which will return just what you passed to it as argument
+// Encrypt implements plaintext encryption which will return what you passed to it as argument wrapped into an envelope
 func (de *PlaintextEncryptor) Encrypt(plaintext, appName string) (string, error) {
 	if plaintext == "" {
 		return "", fmt.Errorf("encrypted value is empty")
 	}
 
-	return plaintext, nil
+	return envelope.Seal(envelope.AlgPlaintext, "", plaintext), nil
 }
 
-// Decrypt implements plaintext decryption which will return just what you passed to it as argument
+// Decrypt implements plaintext decryption which will return the envelope payload or the legacy value as is
 func (de *PlaintextEncryptor) Decrypt(ciphertext, appName string) (string, error) {
 	if ciphertext == "" {
 		return "", fmt.Errorf("decrypted value is empty")
 	}
 
-	return ciphertext, nil
+	return envelope.Open(ciphertext, envelope.AlgPlaintext, "")
 }
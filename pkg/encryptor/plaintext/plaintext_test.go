@@ -5,6 +5,8 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"gotest.tools/assert"
+
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
 )
 
 func TestPlaintextEncryptor(t *testing.T) {
@@ -13,9 +15,20 @@ func TestPlaintextEncryptor(t *testing.T) {
 
 	cipher, err := s.Encrypt("bonjour", "")
 	require.NoError(t, err)
-	assert.Equal(t, "bonjour", cipher)
+	assert.Equal(t, "kgr:v1:plaintext::bonjour", cipher)
 
 	plain, err := s.Decrypt(cipher, "")
 	require.NoError(t, err)
 	assert.Equal(t, "bonjour", plain)
 }
+
+func TestPlaintextEncryptorLegacy(t *testing.T) {
+	s := NewPlaintextEncryptor()
+
+	plain, err := s.Decrypt("bonjour", "")
+	require.NoError(t, err)
+	assert.Equal(t, "bonjour", plain)
+
+	_, err = s.Decrypt(envelope.Seal(envelope.AlgAES, "", "bonjour"), "")
+	require.Error(t, err)
+}
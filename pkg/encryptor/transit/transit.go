@@ -3,10 +3,12 @@ package transit
 import (
 	"encoding/base64"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/hashicorp/vault/api"
+
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
+	"github.com/openware/kaigara/pkg/encryptor/types"
 )
 
 // VaultEncryptor implements Encryptor interface by using Vault transit
@@ -77,7 +79,7 @@ func (s *VaultEncryptor) createTransitKeyIfNotExist(appName string) error {
 		if err != nil {
 			return err
 		}
-		log.Println("INFO: Transit key created")
+		types.Log().Info("transit key created", "app", appName)
 	}
 
 	return nil
@@ -101,17 +103,22 @@ func (s *VaultEncryptor) Encrypt(plaintext, appName string) (string, error) {
 	if !ok {
 		return "", fmt.Errorf("ciphertext not found in Vault response")
 	}
-	return ciphertext.(string), nil
+	return envelope.Seal(envelope.AlgTransit, appName, ciphertext.(string)), nil
 }
 
 // Decrypt the given ciphertext and return the plaintext or an error
 func (s *VaultEncryptor) Decrypt(ciphertext, appName string) (string, error) {
+	payload, err := envelope.Open(ciphertext, envelope.AlgTransit, appName)
+	if err != nil {
+		return "", err
+	}
+
 	if err := s.createTransitKeyIfNotExist(appName); err != nil {
 		return "", err
 	}
 
 	secret, err := s.vault.Logical().Write("transit/decrypt/"+appName, map[string]interface{}{
-		"ciphertext": ciphertext,
+		"ciphertext": payload,
 	})
 	if err != nil {
 		return "", err
@@ -138,7 +145,7 @@ func (s *VaultEncryptor) startRenewToken(token string) error {
 	}
 
 	if !renewable {
-		log.Println("WRN: token is not renewable")
+		types.Log().Warn("Vault token is not renewable")
 		return nil
 	}
 
@@ -155,16 +162,16 @@ func (s *VaultEncryptor) startRenewToken(token string) error {
 		return err
 	}
 
-	log.Println("INF: launching Vault token renewal")
+	types.Log().Info("launching Vault token renewal")
 	go watcher.Start()
 	go func() {
 		for {
 			select {
 			case err := <-watcher.DoneCh():
-				log.Printf("ERR: token renew failed: %s\n", err.Error())
+				types.Log().Error("Vault token renewal failed", "error", err)
 				return
 			case <-watcher.RenewCh():
-				log.Println("INF: successfully renewed token")
+				types.Log().Info("Vault token renewed")
 			}
 		}
 	}()
@@ -1,7 +1,107 @@
 package types
 
+import "fmt"
+
 // Encryptor is used to encrypt/decrypt data for storage drivers
 type Encryptor interface {
 	Encrypt(ciphertext string, appName string) (string, error)
 	Decrypt(ciphertext string, appName string) (string, error)
 }
+
+// BatchEncryptor is implemented by encryptors able to process several values in a single call
+type BatchEncryptor interface {
+	EncryptBatch(plaintexts []string, appName string) ([]string, error)
+	DecryptBatch(ciphertexts []string, appName string) ([]string, error)
+}
+
+// EncryptAll encrypts the plaintexts in a single call if the encryptor supports batches, one by one otherwise
+func EncryptAll(encryptor Encryptor, plaintexts []string, appName string) ([]string, error) {
+	if batch, ok := encryptor.(BatchEncryptor); ok {
+		return batch.EncryptBatch(plaintexts, appName)
+	}
+
+	ciphertexts := make([]string, len(plaintexts))
+	for i, plaintext := range plaintexts {
+		ciphertext, err := encryptor.Encrypt(plaintext, appName)
+		if err != nil {
+			return nil, err
+		}
+		ciphertexts[i] = ciphertext
+	}
+
+	return ciphertexts, nil
+}
+
+// EncryptEntries encodes and encrypts the values of a scope with a single EncryptAll call
+func EncryptEntries(encryptor Encryptor, entries map[string]interface{}, keyName string) (map[string]string, error) {
+	names := make([]string, 0, len(entries))
+	plaintexts := make([]string, 0, len(entries))
+	for name, value := range entries {
+		str, err := EncodeValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s", name, err)
+		}
+		names = append(names, name)
+		plaintexts = append(plaintexts, str)
+	}
+
+	ciphertexts, err := EncryptAll(encryptor, plaintexts, keyName)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string, len(names))
+	for i, name := range names {
+		res[name] = ciphertexts[i]
+	}
+
+	return res, nil
+}
+
+// DecryptAll decrypts the ciphertexts in a single call if the encryptor supports batches, one by one otherwise
+func DecryptAll(encryptor Encryptor, ciphertexts []string, appName string) ([]string, error) {
+	if batch, ok := encryptor.(BatchEncryptor); ok {
+		return batch.DecryptBatch(ciphertexts, appName)
+	}
+
+	plaintexts := make([]string, len(ciphertexts))
+	for i, ciphertext := range ciphertexts {
+		plaintext, err := encryptor.Decrypt(ciphertext, appName)
+		if err != nil {
+			return nil, err
+		}
+		plaintexts[i] = plaintext
+	}
+
+	return plaintexts, nil
+}
+
+// DecryptEntries decrypts and decodes the encrypted values of a scope with a single DecryptAll call
+func DecryptEntries(encryptor Encryptor, entries map[string]interface{}, keyName string) (map[string]interface{}, error) {
+	names := make([]string, 0, len(entries))
+	ciphertexts := make([]string, 0, len(entries))
+	for name, rawValue := range entries {
+		str, ok := rawValue.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid value for %s, must be an encrypted string, got %T", name, rawValue)
+		}
+		names = append(names, name)
+		ciphertexts = append(ciphertexts, str)
+	}
+
+	plaintexts, err := DecryptAll(encryptor, ciphertexts, keyName)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]interface{}, len(names))
+	for i, name := range names {
+		value, err := DecodeValue(plaintexts[i])
+		if err != nil {
+			return nil, err
+		}
+		res[name] = value
+	}
+
+	return res, nil
+}
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Logger writes the logs of the encryptors and the storage drivers, the fields of a line are given as
+// key and value pairs like "app", "finex"
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+var (
+	loggerMu sync.RWMutex
+	logger   Logger = stdLogger{}
+)
+
+// Log returns the logger set with SetLogger, it writes to the standard log package by default
+func Log() Logger {
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+
+	return logger
+}
+
+// SetLogger replaces the logger of the encryptors and the storage drivers, nil restores the default one
+func SetLogger(l Logger) {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+
+	if l == nil {
+		l = stdLogger{}
+	}
+	logger = l
+}
+
+// stdLogger writes lines like "INF: message key=value" with the standard log package, debug lines are dropped
+type stdLogger struct{}
+
+func (stdLogger) Debug(msg string, keysAndValues ...interface{}) {}
+
+func (stdLogger) Info(msg string, keysAndValues ...interface{}) {
+	stdLog("INF", msg, keysAndValues)
+}
+
+func (stdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	stdLog("WRN", msg, keysAndValues)
+}
+
+func (stdLogger) Error(msg string, keysAndValues ...interface{}) {
+	stdLog("ERR", msg, keysAndValues)
+}
+
+func stdLog(prefix, msg string, keysAndValues []interface{}) {
+	var b strings.Builder
+	b.WriteString(prefix + ": " + msg)
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", keysAndValues[i], keysAndValues[i+1])
+	}
+
+	log.Println(b.String())
+}
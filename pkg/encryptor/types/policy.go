@@ -0,0 +1,43 @@
+package types
+
+import "sort"
+
+// DefaultEncryptedScopes are the scopes encrypted at rest when no policy is configured
+var DefaultEncryptedScopes = []string{"secret"}
+
+// ScopePolicy tells storage drivers which scopes must be encrypted at rest
+type ScopePolicy map[string]bool
+
+// NewScopePolicy returns a policy encrypting the given scopes, or DefaultEncryptedScopes if none is given
+func NewScopePolicy(scopes []string) ScopePolicy {
+	policy := ScopePolicy{}
+	for _, scope := range scopes {
+		if scope != "" {
+			policy[scope] = true
+		}
+	}
+
+	if len(policy) == 0 {
+		for _, scope := range DefaultEncryptedScopes {
+			policy[scope] = true
+		}
+	}
+
+	return policy
+}
+
+// IsEncrypted returns true if the values of the scope must be encrypted
+func (p ScopePolicy) IsEncrypted(scope string) bool {
+	return p[scope]
+}
+
+// Scopes returns the sorted list of encrypted scopes
+func (p ScopePolicy) Scopes() []string {
+	scopes := make([]string, 0, len(p))
+	for scope := range p {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	return scopes
+}
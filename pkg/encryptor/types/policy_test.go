@@ -0,0 +1,25 @@
+package types
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestScopePolicy(t *testing.T) {
+	policy := NewScopePolicy([]string{"secret", "private", "credentials"})
+	assert.Equal(t, true, policy.IsEncrypted("secret"))
+	assert.Equal(t, true, policy.IsEncrypted("private"))
+	assert.Equal(t, true, policy.IsEncrypted("credentials"))
+	assert.Equal(t, false, policy.IsEncrypted("public"))
+	assert.DeepEqual(t, []string{"credentials", "private", "secret"}, policy.Scopes())
+}
+
+func TestScopePolicyDefault(t *testing.T) {
+	for _, scopes := range [][]string{nil, {}, {""}} {
+		policy := NewScopePolicy(scopes)
+		assert.Equal(t, true, policy.IsEncrypted("secret"))
+		assert.Equal(t, false, policy.IsEncrypted("private"))
+		assert.DeepEqual(t, DefaultEncryptedScopes, policy.Scopes())
+	}
+}
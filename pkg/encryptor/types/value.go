@@ -0,0 +1,48 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// TypedValuePrefix marks plaintexts holding a JSON encoded value instead of a raw string
+const TypedValuePrefix = "kgr:json:"
+
+// EncodeValue serializes a value of an encrypted scope into the plaintext to encrypt.
+// Strings are kept as is for compatibility with values saved before typed values were supported,
+// any other value (maps, arrays, numbers, booleans) is stored as typed JSON.
+func EncodeValue(value interface{}) (string, error) {
+	if str, ok := value.(string); ok && !strings.HasPrefix(str, TypedValuePrefix) {
+		return str, nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return TypedValuePrefix + string(raw), nil
+}
+
+// DecodeValue restores a value serialized by EncodeValue from a decrypted plaintext.
+// Numbers are returned as json.Number, like the Vault driver does, so integers above 2^53 keep their value.
+func DecodeValue(plaintext string) (interface{}, error) {
+	if !strings.HasPrefix(plaintext, TypedValuePrefix) {
+		return plaintext, nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(strings.TrimPrefix(plaintext, TypedValuePrefix)))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid character after top-level value")
+	}
+
+	return value, nil
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+)
+
+func TestEncodeDecodeValue(t *testing.T) {
+	values := []interface{}{
+		"just a string",
+		"kgr:json:looks like a typed value",
+		true,
+		json.Number("4269"),
+		json.Number("3.14"),
+		json.Number("9007199254740993"),
+		[]interface{}{"value2", "value3"},
+		map[string]interface{}{"key4": "value4", "time": map[string]interface{}{"to": "recover"}},
+	}
+
+	for _, value := range values {
+		plaintext, err := EncodeValue(value)
+		require.NoError(t, err)
+
+		decoded, err := DecodeValue(plaintext)
+		require.NoError(t, err)
+		assert.DeepEqual(t, value, decoded)
+	}
+}
+
+func TestEncodeValueString(t *testing.T) {
+	plaintext, err := EncodeValue("just a string")
+	require.NoError(t, err)
+	assert.Equal(t, "just a string", plaintext)
+
+	plaintext, err = EncodeValue(map[string]interface{}{"key": 1})
+	require.NoError(t, err)
+	assert.Equal(t, `kgr:json:{"key":1}`, plaintext)
+
+	_, err = EncodeValue(func() {})
+	require.Error(t, err)
+
+	_, err = DecodeValue("kgr:json:{")
+	require.Error(t, err)
+
+	_, err = DecodeValue("kgr:json:1 2")
+	require.Error(t, err)
+}
+
+func TestDecodeValueLargeInteger(t *testing.T) {
+	plaintext, err := EncodeValue(int64(9007199254740993))
+	require.NoError(t, err)
+	assert.Equal(t, "kgr:json:9007199254740993", plaintext)
+
+	decoded, err := DecodeValue(plaintext)
+	require.NoError(t, err)
+
+	number, err := decoded.(json.Number).Int64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), number)
+}
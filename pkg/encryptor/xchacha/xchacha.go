@@ -0,0 +1,97 @@
+// Package xchacha implements the Encryptor interface with XChaCha20-Poly1305.
+//
+// Ciphertexts are wrapped into an envelope, their payload is URL-safe base64 encoded and has the following binary layout:
+//
+//	version (1 byte, currently 0x01) | nonce (24 bytes) | sealed plaintext and Poly1305 tag
+package xchacha
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
+)
+
+const (
+	// KeySize is the required key length in bytes
+	KeySize = chacha20poly1305.KeySize
+
+	// FormatV1 is the version byte prepended to every ciphertext
+	FormatV1 byte = 0x01
+)
+
+// XChaChaEncryptor implements Encryptor interface by using XChaCha20-Poly1305
+type XChaChaEncryptor struct {
+	key   []byte
+	keyID string
+}
+
+// NewXChaChaEncryptor instantiate an in memory encryption service
+func NewXChaChaEncryptor(key []byte) (*XChaChaEncryptor, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("XChaCha20-Poly1305 key length should be exactly %d, actual length: %d", KeySize, len(key))
+	}
+
+	return &XChaChaEncryptor{
+		key:   key,
+		keyID: envelope.KeyID(key),
+	}, nil
+}
+
+// Encrypt the plaintext argument and return a ciphertext string or an error
+func (xe *XChaChaEncryptor) Encrypt(plaintext, appName string) (string, error) {
+	aead, err := chacha20poly1305.NewX(xe.key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	data := make([]byte, 0, 1+len(nonce)+len(plaintext)+aead.Overhead())
+	data = append(data, FormatV1)
+	data = append(data, nonce...)
+	data = aead.Seal(data, nonce, []byte(plaintext), nil)
+
+	return envelope.Seal(envelope.AlgXChaCha, xe.keyID, base64.URLEncoding.EncodeToString(data)), nil
+}
+
+// Decrypt the given ciphertext and return the plaintext or an error
+func (xe *XChaChaEncryptor) Decrypt(ciphertext, appName string) (string, error) {
+	payload, err := envelope.Open(ciphertext, envelope.AlgXChaCha, xe.keyID)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.URLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := chacha20poly1305.NewX(xe.key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) < 1+aead.NonceSize()+aead.Overhead() {
+		return "", fmt.Errorf("ciphertext is too short")
+	}
+
+	if data[0] != FormatV1 {
+		return "", fmt.Errorf("unsupported ciphertext format version: %d", data[0])
+	}
+
+	nonce, sealed := data[1:1+aead.NonceSize()], data[1+aead.NonceSize():]
+	plainData, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plainData), nil
+}
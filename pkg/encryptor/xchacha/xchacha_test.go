@@ -0,0 +1,63 @@
+package xchacha
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gotest.tools/assert"
+
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
+)
+
+var key = []byte("0123456789abcdef0123456789abcdef")
+
+func TestXChaChaEncryptorWrongKey(t *testing.T) {
+	_, err := NewXChaChaEncryptor([]byte("1234567890123456"))
+	require.Error(t, err)
+}
+
+func TestXChaChaEncryptor(t *testing.T) {
+	s, err := NewXChaChaEncryptor(key)
+	require.NoError(t, err)
+
+	cipher, err := s.Encrypt("bonjour", "")
+	require.NoError(t, err)
+
+	env, ok := envelope.Parse(cipher)
+	require.True(t, ok)
+	assert.Equal(t, envelope.AlgXChaCha, env.Alg)
+
+	raw, err := base64.URLEncoding.DecodeString(env.Payload)
+	require.NoError(t, err)
+	assert.Equal(t, FormatV1, raw[0])
+
+	plain, err := s.Decrypt(cipher, "")
+	require.NoError(t, err)
+	assert.Equal(t, "bonjour", plain)
+}
+
+func TestXChaChaEncryptorTampered(t *testing.T) {
+	s, err := NewXChaChaEncryptor(key)
+	require.NoError(t, err)
+
+	cipher, err := s.Encrypt("bonjour", "")
+	require.NoError(t, err)
+
+	env, ok := envelope.Parse(cipher)
+	require.True(t, ok)
+
+	raw, err := base64.URLEncoding.DecodeString(env.Payload)
+	require.NoError(t, err)
+
+	raw[len(raw)-1] ^= 0xff
+	_, err = s.Decrypt(base64.URLEncoding.EncodeToString(raw), "")
+	require.Error(t, err)
+
+	raw[0] = 0x02
+	_, err = s.Decrypt(base64.URLEncoding.EncodeToString(raw), "")
+	require.Error(t, err)
+
+	_, err = s.Decrypt("", "")
+	require.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package env
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/openware/kaigara/pkg/config"
+)
+
+// Diff returns the sorted names of the variables and of the files which were added, removed or changed from prev to next
+func Diff(prev, next *config.Env) (vars []string, files []string) {
+	prevVars, nextVars := varsMap(prev.Vars), varsMap(next.Vars)
+
+	for name, value := range nextVars {
+		if prevValue, ok := prevVars[name]; !ok || prevValue != value {
+			vars = append(vars, name)
+		}
+	}
+	for name := range prevVars {
+		if _, ok := nextVars[name]; !ok {
+			vars = append(vars, name)
+		}
+	}
+
+	for name, file := range next.Files {
+		if prevFile, ok := prev.Files[name]; !ok || *prevFile != *file {
+			files = append(files, name)
+		}
+	}
+	for name := range prev.Files {
+		if _, ok := next.Files[name]; !ok {
+			files = append(files, name)
+		}
+	}
+
+	sort.Strings(vars)
+	sort.Strings(files)
+
+	return vars, files
+}
+
+// varsMap indexes NAME=value variables by name, the last value of a duplicated name wins as in os/exec
+func varsMap(vars []string) map[string]string {
+	res := make(map[string]string, len(vars))
+	for _, v := range vars {
+		name, value := v, ""
+		if i := strings.Index(v, "="); i >= 0 {
+			name, value = v[:i], v[i+1:]
+		}
+		res[name] = value
+	}
+
+	return res
+}
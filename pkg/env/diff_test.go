@@ -0,0 +1,48 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/config"
+)
+
+func TestDiff(t *testing.T) {
+	prev := &config.Env{
+		Vars: []string{"HOME=/root", "FINEX_LOG_LEVEL=info", "FINEX_HOST=0.0.0.0", "FINEX_REMOVED=1"},
+		Files: map[string]*config.File{
+			"CERT":   {Path: "/etc/cert.pem", Content: "b2xk"},
+			"CONFIG": {Path: "/etc/config.yml", Content: "Y29uZmln"},
+			"OLD":    {Path: "/etc/old", Content: "b2xk"},
+		},
+	}
+
+	next := &config.Env{
+		Vars: []string{"HOME=/root", "FINEX_LOG_LEVEL=debug", "FINEX_HOST=0.0.0.0", "FINEX_ADDED=1"},
+		Files: map[string]*config.File{
+			"CERT":   {Path: "/etc/cert.pem", Content: "bmV3"},
+			"CONFIG": {Path: "/etc/config.yml", Content: "Y29uZmln"},
+		},
+	}
+
+	vars, files := Diff(prev, next)
+	assert.Equal(t, []string{"FINEX_ADDED", "FINEX_LOG_LEVEL", "FINEX_REMOVED"}, vars)
+	assert.Equal(t, []string{"CERT", "OLD"}, files)
+
+	vars, files = Diff(next, next)
+	assert.Empty(t, vars)
+	assert.Empty(t, files)
+}
+
+func TestDiffDuplicatedVars(t *testing.T) {
+	prev := &config.Env{Vars: []string{"FINEX_HOST=from_pod", "FINEX_HOST=from_storage"}}
+	next := &config.Env{Vars: []string{"FINEX_HOST=from_pod", "FINEX_HOST=from_storage"}}
+
+	vars, _ := Diff(prev, next)
+	assert.Empty(t, vars)
+
+	next.Vars[1] = "FINEX_HOST=updated"
+	vars, _ = Diff(prev, next)
+	assert.Equal(t, []string{"FINEX_HOST"}, vars)
+}
@@ -3,8 +3,10 @@ package env
 import (
 	"encoding/base64"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -12,7 +14,11 @@ import (
 	"github.com/openware/kaigara/types"
 )
 
-var kfile = regexp.MustCompile("(?i)^KFILE_(.*)_(PATH|CONTENT)$")
+var kfile = regexp.MustCompile("(?i)^KFILE_(.*)_(PATH|CONTENT|MODE|OWNER|ENCODING)$")
+var ktpl = regexp.MustCompile("(?i)^KTPL_(.*)_(PATH|TEMPLATE|MODE|OWNER)$")
+
+// ErrStorage is wrapped by the errors of BuildCmdEnv caused by the secret storage, e.g. when it's unreachable
+var ErrStorage = errors.New("failed to load secrets")
 
 func GetCompositeValueB64(v interface{}) (string, error) {
 	raw, err := json.Marshal(v)
@@ -23,31 +29,76 @@ func GetCompositeValueB64(v interface{}) (string, error) {
 	return base64.StdEncoding.EncodeToString(raw), nil
 }
 
-// BuildCmdEnv reads secrets from all secretStores and scopes passed to it and loads them into an Env and returns a *Env
-func BuildCmdEnv(appNames []string, ss types.Storage, currentEnv, scopes []string) (*config.Env, error) {
+// BuildCmdEnv reads secrets from all secretStores and scopes passed to it and loads them into an Env and returns a *Env.
+// The keys are named with the rules, or only upper-cased if rules is nil.
+func BuildCmdEnv(appNames []string, ss types.Storage, currentEnv, scopes []string, rules *Rules) (*config.Env, error) {
+	if rules == nil {
+		rules = defaultRules()
+	}
+
+	stored, err := BuildStoredEnv(appNames, ss, currentEnv, scopes, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return MergeEnv(currentEnv, stored, rules), nil
+}
+
+// BuildStoredEnv returns the variables and files built from the secrets, without the variables of currentEnv,
+// which are only given to the templates
+func BuildStoredEnv(appNames []string, ss types.Storage, currentEnv, scopes []string, rules *Rules) (*config.Env, error) {
+	if rules == nil {
+		rules = defaultRules()
+	}
+
 	env := &config.Env{
 		Vars:  []string{},
 		Files: map[string]*config.File{},
 	}
 
-	for _, v := range currentEnv {
-		if !strings.HasPrefix(v, "KAIGARA_") {
-			env.Vars = append(env.Vars, v)
-		}
-	}
+	// Templates are rendered once all values are loaded, their Content holds the template until then
+	templates := map[string]*config.File{}
+	data := &TemplateData{Apps: map[string]map[string]map[string]interface{}{}}
+
+	vars := newEnvVars(rules.Collision)
+	locked := map[string]bool{}
 
 	for _, appName := range append([]string{"global"}, appNames...) {
 		for _, scope := range scopes {
 			if err := ss.Read(appName, scope); err != nil {
-				return nil, err
+				return nil, fmt.Errorf("%w of %s.%s: %s", ErrStorage, appName, scope, err)
 			}
 
 			secrets, err := ss.GetEntries(appName, scope)
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("%w of %s.%s: %s", ErrStorage, appName, scope, err)
+			}
+
+			if data.Apps[appName] == nil {
+				data.Apps[appName] = map[string]map[string]interface{}{}
 			}
+			data.Apps[appName][scope] = secrets
+
+			// Keys are read in order, so the collisions of keys differing only by case are resolved the same way each time
+			keys := make([]string, 0, len(secrets))
+			for k := range secrets {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				v := secrets[k]
+				if k == LockedKeys {
+					names, err := parseLockedKeys(v)
+					if err != nil {
+						return nil, fmt.Errorf("invalid %s of %s.%s: %s", LockedKeys, appName, scope, err)
+					}
+					for _, name := range names {
+						locked[rules.varName(appName, name)] = true
+					}
+					continue
+				}
 
-			for k, v := range secrets {
 				var val string
 
 				_, isMap := v.(map[string]interface{})
@@ -82,31 +133,90 @@ func BuildCmdEnv(appNames []string, ss types.Storage, currentEnv, scopes []strin
 					}
 				}
 
+				if m := ktpl.FindStringSubmatch(k); m != nil {
+					name := strings.ToUpper(m[1])
+					t, ok := templates[name]
+					if !ok {
+						t = &config.File{}
+						templates[name] = t
+					}
+					setFileField(t, strings.ToUpper(m[2]), val)
+					continue
+				}
+
 				m := kfile.FindStringSubmatch(k)
 
 				if m == nil {
-					env.Vars = append(env.Vars, strings.ToUpper(k)+"="+val)
+					if err := vars.set(rules.varName(appName, k), val, appName, scope); err != nil {
+						return nil, err
+					}
 					continue
 				}
 				name := strings.ToUpper(m[1])
-				suffix := strings.ToUpper(m[2])
 
 				f, ok := env.Files[name]
 				if !ok {
 					f = &config.File{}
 					env.Files[name] = f
 				}
-				switch suffix {
-				case "PATH":
-					f.Path = v.(string)
-				case "CONTENT":
-					f.Content = v.(string)
-				default:
-					log.Printf("ERR: unexpected prefix in config key: %s\n", k)
-				}
+				setFileField(f, strings.ToUpper(m[2]), val)
 			}
 		}
 	}
 
+	env.Vars = append(env.Vars, vars.list()...)
+	for name := range locked {
+		env.Locked = append(env.Locked, name)
+	}
+	sort.Strings(env.Locked)
+
+	for name, f := range env.Files {
+		if f.Content == "" {
+			return nil, fmt.Errorf("file %s needs KFILE_%s_CONTENT", name, name)
+		}
+		if err := CheckFile(name, f); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(templates) > 0 {
+		merged, _ := mergeVars(ProcessVars(currentEnv), env.Vars, env.Locked, rules.Precedence)
+		data.Env = varsMap(merged)
+	}
+	for name, t := range templates {
+		if t.Path == "" || t.Content == "" {
+			return nil, fmt.Errorf("template %s needs both KTPL_%s_PATH and KTPL_%s_TEMPLATE", name, name, name)
+		}
+		if _, ok := env.Files[name]; ok {
+			return nil, fmt.Errorf("file %s is defined by both KFILE and KTPL keys", name)
+		}
+
+		rendered, err := RenderTemplate(name, t.Content, data)
+		if err != nil {
+			return nil, err
+		}
+		env.Files[name] = &config.File{
+			Path:    t.Path,
+			Content: base64.StdEncoding.EncodeToString([]byte(rendered)),
+			Mode:    t.Mode,
+			Owner:   t.Owner,
+		}
+		if err := CheckFile(name, env.Files[name]); err != nil {
+			return nil, err
+		}
+	}
+
 	return env, nil
 }
+
+// ProcessVars returns the variables of the current environment passed to the command, without the KAIGARA_ ones
+func ProcessVars(currentEnv []string) []string {
+	vars := []string{}
+	for _, v := range currentEnv {
+		if !strings.HasPrefix(v, "KAIGARA_") {
+			vars = append(vars, v)
+		}
+	}
+
+	return vars
+}
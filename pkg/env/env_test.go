@@ -68,7 +68,7 @@ func TestBuildCmdEnvFromSecrets(t *testing.T) {
 	err = ss.Write(appNames[0], scopes[0])
 	assert.NoError(t, err)
 
-	r, err := BuildCmdEnv(appNames[1:2], ss, env, scopes)
+	r, err := BuildCmdEnv(appNames[1:2], ss, env, scopes, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -101,7 +101,7 @@ func TestLoadNumberAndBool(t *testing.T) {
 	err = ss.Write(appNames[2], scopes[1])
 	assert.NoError(t, err)
 
-	r, err := BuildCmdEnv(appNames[2:3], ss, env, scopes)
+	r, err := BuildCmdEnv(appNames[2:3], ss, env, scopes, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -123,14 +123,14 @@ func TestBuildCmdEnvFileUpperCase(t *testing.T) {
 	err = ss.SetEntry(appNames[3], scopes[1], "KFILE_NAME_PATH", "config/config.json")
 	assert.NoError(t, err)
 
-	err = ss.SetEntry(appNames[3], scopes[1], "KFILE_NAME_CONTENT", `{"app":"example"}`)
+	err = ss.SetEntry(appNames[3], scopes[1], "KFILE_NAME_CONTENT", "eyJhcHAiOiJleGFtcGxlIn0=")
 	assert.NoError(t, err)
 
 	err = ss.Write(appNames[3], scopes[1])
 	assert.NoError(t, err)
 
 	env := []string{}
-	r, err := BuildCmdEnv(appNames[3:4], ss, env, scopes)
+	r, err := BuildCmdEnv(appNames[3:4], ss, env, scopes, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -142,7 +142,7 @@ func TestBuildCmdEnvFileUpperCase(t *testing.T) {
 		Files: map[string]*config.File{
 			"NAME": {
 				Path:    "config/config.json",
-				Content: `{"app":"example"}`,
+				Content: "eyJhcHAiOiJleGFtcGxlIn0=",
 			},
 		},
 	}, r)
@@ -158,14 +158,14 @@ func TestBuildCmdEnvFileLowerCase(t *testing.T) {
 	err = ss.SetEntry(appNames[4], scopes[1], "kfile_name_path", "config/config.json")
 	assert.NoError(t, err)
 
-	err = ss.SetEntry(appNames[4], scopes[1], "kfile_name_content", `{"app":"example"}`)
+	err = ss.SetEntry(appNames[4], scopes[1], "kfile_name_content", "eyJhcHAiOiJleGFtcGxlIn0=")
 	assert.NoError(t, err)
 
 	err = ss.Write(appNames[4], scopes[1])
 	assert.NoError(t, err)
 
 	env := []string{}
-	r, err := BuildCmdEnv(appNames[4:5], ss, env, scopes)
+	r, err := BuildCmdEnv(appNames[4:5], ss, env, scopes, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -177,7 +177,7 @@ func TestBuildCmdEnvFileLowerCase(t *testing.T) {
 		Files: map[string]*config.File{
 			"NAME": {
 				Path:    "config/config.json",
-				Content: `{"app":"example"}`,
+				Content: "eyJhcHAiOiJleGFtcGxlIn0=",
 			},
 		},
 	}, r)
@@ -203,7 +203,7 @@ func TestBuildCmdEnvSeveralAppNames(t *testing.T) {
 	assert.NoError(t, err)
 
 	env := []string{}
-	r, err := BuildCmdEnv(appNames[5:], ss, env, scopes)
+	r, err := BuildCmdEnv(appNames[5:], ss, env, scopes, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
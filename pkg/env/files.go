@@ -0,0 +1,191 @@
+package env
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/openware/kaigara/pkg/config"
+)
+
+// Encodings of the KFILE content, set with KFILE_<NAME>_ENCODING
+const (
+	EncodingBase64     = "base64"
+	EncodingRaw        = "raw"
+	EncodingGzipBase64 = "gzip+base64"
+)
+
+// Default permissions of the written files and of their directories
+const (
+	DefaultFileMode os.FileMode = 0640
+	DefaultDirMode  os.FileMode = 0750
+)
+
+// setFileField sets the field of the file named by the KFILE or KTPL key suffix
+func setFileField(f *config.File, suffix, value string) {
+	switch suffix {
+	case "PATH":
+		f.Path = value
+	case "CONTENT", "TEMPLATE":
+		f.Content = value
+	case "MODE":
+		f.Mode = value
+	case "OWNER":
+		f.Owner = value
+	case "ENCODING":
+		f.Encoding = value
+	}
+}
+
+// CheckFile returns an error if the file can't be written
+func CheckFile(name string, f *config.File) error {
+	if f.Path == "" {
+		return fmt.Errorf("file %s has no path", name)
+	}
+	if _, err := DecodeFile(f); err != nil {
+		return fmt.Errorf("file %s has an invalid content: %s", name, err)
+	}
+	if _, err := fileMode(f); err != nil {
+		return fmt.Errorf("file %s has an invalid mode: %s", name, err)
+	}
+	if _, _, err := fileOwner(f); err != nil {
+		return fmt.Errorf("file %s has an invalid owner: %s", name, err)
+	}
+
+	return nil
+}
+
+// DecodeFile returns the content of the file decoded with its encoding, base64 by default
+func DecodeFile(f *config.File) ([]byte, error) {
+	switch f.Encoding {
+	case EncodingBase64, "":
+		return base64.StdEncoding.DecodeString(f.Content)
+
+	case EncodingRaw:
+		return []byte(f.Content), nil
+
+	case EncodingGzipBase64:
+		compressed, err := base64.StdEncoding.DecodeString(f.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		return io.ReadAll(reader)
+
+	default:
+		return nil, fmt.Errorf("encoding '%s' is not supported, use %s, %s or %s", f.Encoding, EncodingBase64, EncodingRaw, EncodingGzipBase64)
+	}
+}
+
+// WriteFile writes the file atomically, to a temporary file in the same directory renamed into place,
+// with its mode and owner if set
+func WriteFile(f *config.File) error {
+	content, err := DecodeFile(f)
+	if err != nil {
+		return fmt.Errorf("failed to decode content: %s", err)
+	}
+	mode, err := fileMode(f)
+	if err != nil {
+		return err
+	}
+	uid, gid, err := fileOwner(f)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(f.Path)
+	if err := os.MkdirAll(dir, DefaultDirMode); err != nil {
+		return fmt.Errorf("failed to make dir %s: %s", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if f.Owner != "" {
+		if err := os.Chown(tmp.Name(), uid, gid); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// fileMode parses the octal mode of the file, DefaultFileMode if it's not set
+func fileMode(f *config.File) (os.FileMode, error) {
+	if f.Mode == "" {
+		return DefaultFileMode, nil
+	}
+
+	mode, err := strconv.ParseUint(f.Mode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("mode '%s' isn't an octal permission like 0600", f.Mode)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+// fileOwner resolves the user[:group] owner of the file, names or ids, -1 is returned for unset ids
+func fileOwner(f *config.File) (int, int, error) {
+	if f.Owner == "" {
+		return -1, -1, nil
+	}
+
+	name, group, _ := strings.Cut(f.Owner, ":")
+
+	uid, err := strconv.Atoi(name)
+	if err != nil {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return 0, 0, err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, fmt.Errorf("user %s has no numeric id", name)
+		}
+	}
+
+	gid := -1
+	if group != "" {
+		if gid, err = strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return 0, 0, err
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return 0, 0, fmt.Errorf("group %s has no numeric id", group)
+			}
+		}
+	}
+
+	return uid, gid, nil
+}
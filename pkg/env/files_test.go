@@ -0,0 +1,111 @@
+package env
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/config"
+)
+
+func TestDecodeFile(t *testing.T) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte("app: example"))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	for _, f := range []*config.File{
+		{Content: "YXBwOiBleGFtcGxl"},
+		{Content: "YXBwOiBleGFtcGxl", Encoding: EncodingBase64},
+		{Content: "app: example", Encoding: EncodingRaw},
+		{Content: base64.StdEncoding.EncodeToString(buf.Bytes()), Encoding: EncodingGzipBase64},
+	} {
+		content, err := DecodeFile(f)
+		assert.NoError(t, err)
+		assert.Equal(t, "app: example", string(content))
+	}
+
+	_, err = DecodeFile(&config.File{Content: "app: example"})
+	assert.Error(t, err)
+
+	_, err = DecodeFile(&config.File{Content: "YXBwOiBleGFtcGxl", Encoding: EncodingGzipBase64})
+	assert.Error(t, err)
+
+	_, err = DecodeFile(&config.File{Content: "app: example", Encoding: "hex"})
+	assert.ErrorContains(t, err, "encoding 'hex' is not supported")
+}
+
+func TestCheckFile(t *testing.T) {
+	assert.NoError(t, CheckFile("CONFIG", &config.File{Path: "/etc/app.yml", Content: "YXBw", Mode: "0600"}))
+
+	assert.ErrorContains(t, CheckFile("CONFIG", &config.File{Content: "YXBw"}), "file CONFIG has no path")
+	assert.ErrorContains(t, CheckFile("CONFIG", &config.File{Path: "/etc/app.yml", Content: "app"}), "invalid content")
+	assert.ErrorContains(t, CheckFile("CONFIG", &config.File{Path: "/etc/app.yml", Content: "YXBw", Mode: "0999"}), "invalid mode")
+	assert.ErrorContains(t, CheckFile("CONFIG", &config.File{Path: "/etc/app.yml", Content: "YXBw", Mode: "01777"}), "invalid mode")
+	assert.ErrorContains(t, CheckFile("CONFIG", &config.File{Path: "/etc/app.yml", Content: "YXBw", Owner: "kaigara-missing-user"}), "invalid owner")
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", "app.yml")
+
+	assert.NoError(t, WriteFile(&config.File{Path: path, Content: "app: example", Encoding: EncodingRaw}))
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "app: example", string(content))
+
+	assert.NoError(t, WriteFile(&config.File{Path: path, Content: "YXBwOiBmaW5leA==", Mode: "0600"}))
+	content, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "app: finex", string(content))
+
+	// No temporary file is left next to the written file
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Owning the file by the current user is always allowed
+	owner := fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+	assert.NoError(t, WriteFile(&config.File{Path: path, Content: "YXBwOiBmaW5leA==", Owner: owner}))
+
+	info, err = os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultFileMode, info.Mode().Perm())
+}
+
+func TestBuildCmdEnvInvalidFile(t *testing.T) {
+	ss := newTestStorage(t, map[string]map[string]interface{}{
+		"finex": {
+			"kfile_config_path":    "/etc/finex/config.yml",
+			"kfile_config_content": "app: finex",
+		},
+	})
+
+	_, err := BuildCmdEnv([]string{"finex"}, ss, []string{}, []string{"secret"}, nil)
+	assert.ErrorContains(t, err, "file CONFIG has an invalid content")
+
+	assert.NoError(t, ss.SetEntries("finex", "secret", map[string]interface{}{
+		"kfile_config_encoding": "raw",
+		"kfile_config_mode":     "0600",
+	}))
+	assert.NoError(t, ss.Write("finex", "secret"))
+
+	env, err := BuildCmdEnv([]string{"finex"}, ss, []string{}, []string{"secret"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &config.File{Path: "/etc/finex/config.yml", Content: "app: finex", Mode: "0600", Encoding: "raw"}, env.Files["CONFIG"])
+}
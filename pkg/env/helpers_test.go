@@ -0,0 +1,22 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/k8s"
+	"github.com/openware/kaigara/types"
+)
+
+// newTestStorage returns a storage holding the entries in the secret scope of each app, encrypted with AES
+func newTestStorage(t *testing.T, secrets map[string]map[string]interface{}) types.Storage {
+	ss := k8s.NewMockService(k8s.NewMockClient())
+	for app, entries := range secrets {
+		assert.NoError(t, ss.Read(app, "secret"))
+		assert.NoError(t, ss.SetEntries(app, "secret", entries))
+		assert.NoError(t, ss.Write(app, "secret"))
+	}
+
+	return ss
+}
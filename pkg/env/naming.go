@@ -0,0 +1,151 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openware/kaigara/pkg/config"
+	"github.com/openware/kaigara/pkg/logger"
+)
+
+// Collision policies of the variables defined by several apps or scopes, set with KAIGARA_ENV_COLLISION
+const (
+	// CollisionError fails to build the environment
+	CollisionError = "error"
+	// CollisionAppWins keeps the value loaded last: apps override global, and later apps and scopes override earlier ones
+	CollisionAppWins = "app-wins"
+	// CollisionFirstWins keeps the value loaded first
+	CollisionFirstWins = "first-wins"
+)
+
+// Rules tell how the stored keys are named in the environment of the command
+type Rules struct {
+	// Prefixes are the prefixes of the variables of each app
+	Prefixes map[string]string
+	// Renames are the names of the variables of each app, by upper-cased key
+	Renames map[string]map[string]string
+	// Collision is the policy of the variables defined several times
+	Collision string
+	// Precedence tells if the stored values or the environment of kaigara win
+	Precedence string
+}
+
+// NewRules returns the naming rules of the configuration: prefixes by app like peatio:PEATIO_,
+// renames by app.key like peatio.database_host:PEATIO_DB_HOST, the collision policy and the precedence
+func NewRules(conf *config.KaigaraConfig) (*Rules, error) {
+	if err := checkPrecedence(conf.EnvPrecedence); err != nil {
+		return nil, err
+	}
+
+	switch conf.EnvCollision {
+	case CollisionError, CollisionAppWins, CollisionFirstWins:
+	default:
+		return nil, fmt.Errorf("env collision policy '%s' is not supported, use %s, %s or %s", conf.EnvCollision, CollisionError, CollisionAppWins, CollisionFirstWins)
+	}
+
+	rules := &Rules{
+		Prefixes:   map[string]string{},
+		Renames:    map[string]map[string]string{},
+		Collision:  conf.EnvCollision,
+		Precedence: conf.EnvPrecedence,
+	}
+
+	for app, prefix := range conf.EnvPrefixes {
+		rules.Prefixes[app] = strings.ToUpper(prefix)
+	}
+
+	for key, name := range conf.EnvRenames {
+		app, k, ok := strings.Cut(key, ".")
+		if !ok || app == "" || k == "" || name == "" {
+			return nil, fmt.Errorf("env rename '%s:%s' is invalid, use app.key:NAME", key, name)
+		}
+		if rules.Renames[app] == nil {
+			rules.Renames[app] = map[string]string{}
+		}
+		rules.Renames[app][strings.ToUpper(k)] = name
+	}
+
+	return rules, nil
+}
+
+// defaultRules only upper-case the keys, apps override global and the stored values override the environment
+func defaultRules() *Rules {
+	return &Rules{Collision: CollisionAppWins, Precedence: PrecedenceStorage}
+}
+
+// varName returns the name of the variable of the key stored in the app
+func (r *Rules) varName(app, key string) string {
+	key = strings.ToUpper(key)
+	if name, ok := r.Renames[app][key]; ok {
+		return name
+	}
+
+	return r.Prefixes[app] + key
+}
+
+// envVars collects the variables of the command, the ones defined several times are resolved with the collision policy
+type envVars struct {
+	collision string
+	names     []string
+	values    map[string]string
+	sources   map[string][]string
+	// loaded are the values already loaded by app, drivers like k8s return the same document for every scope of an app
+	loaded map[loadedVar]bool
+}
+
+type loadedVar struct {
+	name, app, value string
+}
+
+func newEnvVars(collision string) *envVars {
+	return &envVars{
+		collision: collision,
+		values:    map[string]string{},
+		sources:   map[string][]string{},
+		loaded:    map[loadedVar]bool{},
+	}
+}
+
+// set defines the variable loaded from the scope of the app. Loading the same value again from another scope
+// of the same app isn't a collision, since it's read from the same stored document.
+func (v *envVars) set(name, value, app, scope string) error {
+	loaded := loadedVar{name: name, app: app, value: value}
+	if v.loaded[loaded] {
+		return nil
+	}
+	v.loaded[loaded] = true
+
+	source := app + "." + scope
+	prev, defined := v.sources[name]
+	v.sources[name] = append(prev, source)
+
+	if !defined {
+		v.names = append(v.names, name)
+		v.values[name] = value
+		return nil
+	}
+
+	switch v.collision {
+	case CollisionError:
+		return fmt.Errorf("variable %s is defined by both %s and %s", name, strings.Join(prev, ", "), source)
+	case CollisionAppWins:
+		v.values[name] = value
+	}
+
+	return nil
+}
+
+// list returns the variables like NAME=value in the order they were first defined, and warns about the ones
+// defined several times
+func (v *envVars) list() []string {
+	vars := make([]string, 0, len(v.names))
+	for _, name := range v.names {
+		if sources := v.sources[name]; len(sources) > 1 {
+			logger.Warn("environment variable is defined several times", logger.F("name", name),
+				logger.F("sources", sources), logger.F("policy", v.collision))
+		}
+		vars = append(vars, name+"="+v.values[name])
+	}
+
+	return vars
+}
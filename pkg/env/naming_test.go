@@ -0,0 +1,117 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/config"
+	"github.com/openware/kaigara/types"
+)
+
+func newNamingStorage(t *testing.T) types.Storage {
+	return newTestStorage(t, map[string]map[string]interface{}{
+		"global": {"log_level": "info", "database_host": "global-db"},
+		"peatio": {"database_host": "peatio-db", "DATABASE_HOST": "PEATIO-DB"},
+		"barong": {"log_level": "debug", "database_host": "barong-db"},
+	})
+}
+
+func TestNewRules(t *testing.T) {
+	rules, err := NewRules(&config.KaigaraConfig{
+		EnvPrefixes:   map[string]string{"peatio": "peatio_"},
+		EnvRenames:    map[string]string{"barong.database_host": "BARONG_DB_HOST"},
+		EnvCollision:  CollisionError,
+		EnvPrecedence: PrecedenceStorage,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "PEATIO_DATABASE_HOST", rules.varName("peatio", "database_host"))
+	assert.Equal(t, "BARONG_DB_HOST", rules.varName("barong", "DATABASE_HOST"))
+	assert.Equal(t, "LOG_LEVEL", rules.varName("barong", "log_level"))
+
+	_, err = NewRules(&config.KaigaraConfig{EnvCollision: "last-wins", EnvPrecedence: PrecedenceStorage})
+	assert.ErrorContains(t, err, "env collision policy 'last-wins' is not supported")
+
+	_, err = NewRules(&config.KaigaraConfig{EnvRenames: map[string]string{"database_host": "DB_HOST"}, EnvCollision: CollisionAppWins, EnvPrecedence: PrecedenceStorage})
+	assert.ErrorContains(t, err, "use app.key:NAME")
+}
+
+func TestBuildCmdEnvCollisions(t *testing.T) {
+	ss := newNamingStorage(t)
+	apps := []string{"peatio", "barong"}
+
+	env, err := BuildCmdEnv(apps, ss, []string{}, []string{"secret"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"DATABASE_HOST=barong-db", "LOG_LEVEL=debug"}, env.Vars)
+
+	env, err = BuildCmdEnv(apps, ss, []string{}, []string{"secret"}, &Rules{Collision: CollisionFirstWins})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"DATABASE_HOST=global-db", "LOG_LEVEL=info"}, env.Vars)
+
+	_, err = BuildCmdEnv(apps, ss, []string{}, []string{"secret"}, &Rules{Collision: CollisionError})
+	assert.EqualError(t, err, "variable DATABASE_HOST is defined by both global.secret and peatio.secret")
+
+	// Keys only differing by case are a collision too
+	_, err = BuildCmdEnv([]string{"peatio"}, ss, []string{}, []string{"secret"}, &Rules{
+		Prefixes:  map[string]string{"peatio": "PEATIO_"},
+		Collision: CollisionError,
+	})
+	assert.EqualError(t, err, "variable PEATIO_DATABASE_HOST is defined by both peatio.secret and peatio.secret")
+}
+
+func TestBuildCmdEnvNaming(t *testing.T) {
+	ss := newNamingStorage(t)
+
+	rules, err := NewRules(&config.KaigaraConfig{
+		EnvPrefixes:   map[string]string{"peatio": "PEATIO_", "barong": "BARONG_"},
+		EnvRenames:    map[string]string{"peatio.database_host": "PEATIO_DB_HOST", "barong.log_level": "BARONG_LOGGING"},
+		EnvCollision:  CollisionFirstWins,
+		EnvPrecedence: PrecedenceStorage,
+	})
+	assert.NoError(t, err)
+
+	// Both keys of peatio are renamed the same way, the first one in order is kept
+	env, err := BuildCmdEnv([]string{"peatio", "barong"}, ss, []string{}, []string{"secret"}, rules)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"DATABASE_HOST=global-db",
+		"LOG_LEVEL=info",
+		"PEATIO_DB_HOST=PEATIO-DB",
+		"BARONG_DATABASE_HOST=barong-db",
+		"BARONG_LOGGING=debug",
+	}, env.Vars)
+}
+
+func TestBuildCmdEnvSharedScopes(t *testing.T) {
+	// The k8s driver returns the whole secret of an app for each of its scopes
+	ss := newTestStorage(t, map[string]map[string]interface{}{
+		"global": {"log_level": "info"},
+		"finex":  {"database_host": "finex-db"},
+		"barong": {"log_level": "debug"},
+	})
+	scopes := []string{"public", "private", "secret"}
+
+	for _, collision := range []string{CollisionError, CollisionAppWins, CollisionFirstWins} {
+		env, err := BuildCmdEnv([]string{"finex"}, ss, []string{}, scopes, &Rules{Collision: collision})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"LOG_LEVEL=info", "DATABASE_HOST=finex-db"}, env.Vars)
+	}
+
+	_, err := BuildCmdEnv([]string{"barong"}, ss, []string{}, scopes, &Rules{Collision: CollisionError})
+	assert.EqualError(t, err, "variable LOG_LEVEL is defined by both global.public and barong.public")
+
+	env, err := BuildCmdEnv([]string{"barong"}, ss, []string{}, scopes, &Rules{Collision: CollisionAppWins})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"LOG_LEVEL=debug"}, env.Vars)
+
+	env, err = BuildCmdEnv([]string{"barong"}, ss, []string{}, scopes, &Rules{Collision: CollisionFirstWins})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"LOG_LEVEL=info"}, env.Vars)
+
+	// A value loaded again from the same app isn't reported as defined several times
+	vars := newEnvVars(CollisionError)
+	for _, scope := range scopes {
+		assert.NoError(t, vars.set("DATABASE_HOST", "finex-db", "finex", scope))
+	}
+	assert.Equal(t, []string{"finex.public"}, vars.sources["DATABASE_HOST"])
+}
@@ -0,0 +1,117 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openware/kaigara/pkg/config"
+	"github.com/openware/kaigara/pkg/logger"
+)
+
+// Precedences between the environment kaigara runs in and the stored values, set with KAIGARA_ENV_PRECEDENCE
+const (
+	// PrecedenceStorage overrides the variables of the environment with the stored values
+	PrecedenceStorage = "storage-over-env"
+	// PrecedenceEnv keeps the variables of the environment over the stored values, except the locked ones
+	PrecedenceEnv = "env-over-storage"
+)
+
+// LockedKeys is the reserved key listing the keys of an app which can't be overridden by the environment,
+// as a list or a comma-separated string
+const LockedKeys = "kaigara_locked_keys"
+
+// checkPrecedence returns an error if the precedence is unknown
+func checkPrecedence(precedence string) error {
+	switch precedence {
+	case PrecedenceStorage, PrecedenceEnv:
+		return nil
+	default:
+		return fmt.Errorf("env precedence '%s' is not supported, use %s or %s", precedence, PrecedenceStorage, PrecedenceEnv)
+	}
+}
+
+// parseLockedKeys returns the keys listed by the value of LockedKeys
+func parseLockedKeys(value interface{}) ([]string, error) {
+	keys := []string{}
+	switch v := value.(type) {
+	case string:
+		for _, key := range strings.Split(v, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	case []interface{}:
+		for _, key := range v {
+			s, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must only hold key names, got %v", LockedKeys, key)
+			}
+			keys = append(keys, s)
+		}
+	default:
+		return nil, fmt.Errorf("%s must be a list or a comma-separated string of key names", LockedKeys)
+	}
+
+	return keys, nil
+}
+
+// MergeEnv returns the environment of the command: the variables of currentEnv, without the KAIGARA_ ones,
+// merged with the stored ones according to the precedence of the rules, each variable being defined once
+func MergeEnv(currentEnv []string, stored *config.Env, rules *Rules) *config.Env {
+	if rules == nil {
+		rules = defaultRules()
+	}
+
+	vars, ignored := mergeVars(ProcessVars(currentEnv), stored.Vars, stored.Locked, rules.Precedence)
+	for _, name := range ignored {
+		logger.Warn("environment variable is locked in storage, its value in the environment is ignored", logger.F("name", name))
+	}
+
+	return &config.Env{Vars: vars, Files: stored.Files, Locked: stored.Locked}
+}
+
+// mergeVars merges the variables of the process with the stored ones, the variables of the process come first.
+// It returns the variables of the process ignored because they're locked with env-over-storage.
+func mergeVars(process, stored, locked []string, precedence string) (vars []string, ignored []string) {
+	storedVars := varsMap(stored)
+	processVars := varsMap(process)
+	lockedNames := map[string]bool{}
+	for _, name := range locked {
+		lockedNames[name] = true
+	}
+
+	// The value of a variable defined several times in the process is its last one, as for exec
+	last := map[string]int{}
+	for i, v := range process {
+		last[strings.SplitN(v, "=", 2)[0]] = i
+	}
+
+	vars = []string{}
+	for i, v := range process {
+		name := strings.SplitN(v, "=", 2)[0]
+		if last[name] != i {
+			continue
+		}
+
+		if _, ok := storedVars[name]; ok {
+			if precedence != PrecedenceEnv {
+				continue
+			}
+			if lockedNames[name] {
+				ignored = append(ignored, name)
+				continue
+			}
+		}
+		vars = append(vars, v)
+	}
+
+	for _, v := range stored {
+		name := strings.SplitN(v, "=", 2)[0]
+		if _, ok := processVars[name]; ok && precedence == PrecedenceEnv && !lockedNames[name] {
+			continue
+		}
+		vars = append(vars, v)
+	}
+
+	return vars, ignored
+}
@@ -0,0 +1,75 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/config"
+)
+
+func TestMergeVars(t *testing.T) {
+	process := []string{"PATH=/bin", "DATABASE_HOST=pod-db", "LOG_LEVEL=warn", "PATH=/usr/bin"}
+	stored := []string{"DATABASE_HOST=stored-db", "LOG_LEVEL=info", "DATABASE_PORT=5432"}
+
+	vars, ignored := mergeVars(process, stored, []string{"LOG_LEVEL"}, PrecedenceStorage)
+	assert.Equal(t, []string{"PATH=/usr/bin", "DATABASE_HOST=stored-db", "LOG_LEVEL=info", "DATABASE_PORT=5432"}, vars)
+	assert.Empty(t, ignored)
+
+	vars, ignored = mergeVars(process, stored, []string{"LOG_LEVEL"}, PrecedenceEnv)
+	assert.Equal(t, []string{"DATABASE_HOST=pod-db", "PATH=/usr/bin", "LOG_LEVEL=info", "DATABASE_PORT=5432"}, vars)
+	assert.Equal(t, []string{"LOG_LEVEL"}, ignored)
+}
+
+func TestParseLockedKeys(t *testing.T) {
+	keys, err := parseLockedKeys("database_host, database_port,")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"database_host", "database_port"}, keys)
+
+	keys, err = parseLockedKeys([]interface{}{"database_host"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"database_host"}, keys)
+
+	_, err = parseLockedKeys([]interface{}{42})
+	assert.ErrorContains(t, err, "must only hold key names")
+
+	_, err = parseLockedKeys(true)
+	assert.ErrorContains(t, err, "must be a list or a comma-separated string")
+
+	_, err = NewRules(&config.KaigaraConfig{EnvCollision: CollisionAppWins, EnvPrecedence: "env"})
+	assert.ErrorContains(t, err, "env precedence 'env' is not supported")
+}
+
+func TestBuildCmdEnvPrecedence(t *testing.T) {
+	ss := newTestStorage(t, map[string]map[string]interface{}{
+		"peatio": {
+			"database_host": "stored-db",
+			"database_user": "peatio",
+			LockedKeys:      []interface{}{"database_user"},
+		},
+	})
+
+	current := []string{"DATABASE_HOST=pod-db", "DATABASE_USER=root", "KAIGARA_SCOPES=secret"}
+
+	env, err := BuildCmdEnv([]string{"peatio"}, ss, current, []string{"secret"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &config.Env{
+		Vars:   []string{"DATABASE_HOST=stored-db", "DATABASE_USER=peatio"},
+		Files:  map[string]*config.File{},
+		Locked: []string{"DATABASE_USER"},
+	}, env)
+
+	// The locked keys are named like the variables
+	rules := &Rules{Prefixes: map[string]string{"peatio": "PEATIO_"}, Collision: CollisionAppWins, Precedence: PrecedenceEnv}
+	env, err = BuildCmdEnv([]string{"peatio"}, ss, []string{"PEATIO_DATABASE_HOST=pod-db", "PEATIO_DATABASE_USER=root"}, []string{"secret"}, rules)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"PEATIO_DATABASE_HOST=pod-db", "PEATIO_DATABASE_USER=peatio"}, env.Vars)
+	assert.Equal(t, []string{"PEATIO_DATABASE_USER"}, env.Locked)
+
+	// The stored env doesn't hold the variables of the environment, and can be merged again
+	stored, err := BuildStoredEnv([]string{"peatio"}, ss, current, []string{"secret"}, rules)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"PEATIO_DATABASE_HOST=stored-db", "PEATIO_DATABASE_USER=peatio"}, stored.Vars)
+	assert.Equal(t, []string{"PATH=/bin", "PEATIO_DATABASE_HOST=stored-db", "PEATIO_DATABASE_USER=peatio"},
+		MergeEnv([]string{"PATH=/bin", "PEATIO_DATABASE_USER=root"}, stored, rules).Vars)
+}
@@ -0,0 +1,98 @@
+package env
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateData is given to KTPL templates
+type TemplateData struct {
+	// Env holds the environment variables of the command
+	Env map[string]string
+	// Apps holds the values loaded from the storage per app and scope, as stored
+	Apps map[string]map[string]map[string]interface{}
+}
+
+var templateFuncs = template.FuncMap{
+	"base64":   templateBase64,
+	"json":     templateJSON,
+	"toYaml":   templateYAML,
+	"default":  templateDefault,
+	"required": templateRequired,
+}
+
+// RenderTemplate renders the text/template with the data and the helper functions
+func RenderTemplate(name, text string, data *TemplateData) (string, error) {
+	tpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %s", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %s", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+func templateBase64(v interface{}) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(v)))
+}
+
+func templateJSON(v interface{}) (string, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(raw), nil
+}
+
+func templateYAML(v interface{}) (string, error) {
+	raw, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(raw), "\n"), nil
+}
+
+// templateDefault returns the value, or def if it's missing or empty, e.g. {{ .Env.PORT | default "8080" }}
+func templateDefault(def interface{}, v ...interface{}) interface{} {
+	if len(v) == 0 || isEmpty(v[0]) {
+		return def
+	}
+
+	return v[0]
+}
+
+// templateRequired fails with the message if the value is missing or empty, e.g. {{ required "password is missing" .Env.PASSWORD }}
+func templateRequired(message string, v interface{}) (interface{}, error) {
+	if isEmpty(v) {
+		return nil, fmt.Errorf("%s", message)
+	}
+
+	return v, nil
+}
+
+// isEmpty tells if a value is nil, or an empty string, map or slice
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.String, reflect.Map, reflect.Slice:
+		return rv.Len() == 0
+	}
+
+	return false
+}
@@ -0,0 +1,67 @@
+package env
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	data := &TemplateData{
+		Env: map[string]string{"FINEX_DATABASE_HOST": "db", "FINEX_DATABASE_PASSWORD": "changeme"},
+		Apps: map[string]map[string]map[string]interface{}{
+			"finex": {"secret": {"markets": []interface{}{"btcusd", "ethusd"}}},
+		},
+	}
+
+	rendered, err := RenderTemplate("database", `host: {{ .Env.FINEX_DATABASE_HOST }}
+port: {{ .Env.FINEX_DATABASE_PORT | default "5432" }}
+password: {{ required "password is missing" .Env.FINEX_DATABASE_PASSWORD | base64 }}
+markets: {{ json .Apps.finex.secret.markets }}
+{{ toYaml .Apps.finex.secret }}`, data)
+	assert.NoError(t, err)
+	assert.Equal(t, `host: db
+port: 5432
+password: Y2hhbmdlbWU=
+markets: ["btcusd","ethusd"]
+markets:
+    - btcusd
+    - ethusd`, rendered)
+
+	_, err = RenderTemplate("database", `{{ required "user is missing" .Env.FINEX_DATABASE_USER }}`, data)
+	assert.ErrorContains(t, err, "user is missing")
+
+	_, err = RenderTemplate("database", `{{ .Env.FINEX_DATABASE_HOST`, data)
+	assert.ErrorContains(t, err, "failed to parse template database")
+}
+
+func TestBuildCmdEnvTemplates(t *testing.T) {
+	ss := newTestStorage(t, map[string]map[string]interface{}{
+		"finex": {
+			"finex_database_host": "db",
+			"ktpl_database_path":  "/etc/finex/database.yml",
+			"ktpl_database_template": `host: {{ .Env.FINEX_DATABASE_HOST }}
+env: {{ .Env.FINEX_ENV }}`,
+		},
+	})
+
+	env, err := BuildCmdEnv([]string{"finex"}, ss, []string{"FINEX_ENV=production"}, []string{"secret"}, nil)
+	assert.NoError(t, err)
+	assert.NotContains(t, env.Vars, "KTPL_DATABASE_PATH=/etc/finex/database.yml")
+
+	file := env.Files["DATABASE"]
+	assert.NotNil(t, file)
+	assert.Equal(t, "/etc/finex/database.yml", file.Path)
+	content, err := base64.StdEncoding.DecodeString(file.Content)
+	assert.NoError(t, err)
+	assert.Equal(t, "host: db\nenv: production", string(content))
+
+	// A template without path can't be rendered
+	assert.NoError(t, ss.Read("gotrue", "secret"))
+	assert.NoError(t, ss.SetEntry("gotrue", "secret", "ktpl_config_template", "{{ .Env.GOTRUE_HOST }}"))
+	assert.NoError(t, ss.Write("gotrue", "secret"))
+
+	_, err = BuildCmdEnv([]string{"gotrue"}, ss, []string{}, []string{"secret"}, nil)
+	assert.ErrorContains(t, err, "KTPL_CONFIG_PATH")
+}
@@ -10,7 +10,8 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 )
 
-// Service contains a K8s client
+// Service contains a K8s client.
+// All scopes of an app are stored in a single secret, so the values of every scope are encrypted.
 type Service struct {
 	client       *kube.K8sClient
 	deploymentID string
@@ -18,6 +19,8 @@ type Service struct {
 	encryptor    types.Encryptor
 }
 
+// NewService returns the k8s driver, it takes no scope policy since all the scopes of an app share one secret
+// and every value is encrypted
 func NewService(deploymentID string, client *kube.K8sClient, encryptor types.Encryptor) (*Service, error) {
 	return &Service{
 		client:       client,
@@ -85,14 +88,9 @@ func (ss *Service) Write(appName, scope string) error {
 		}
 	}
 
-	// update version from exiting secret, create version = 0 for a new secret
-	newVersion := int64(0)
-	if version, ok := secrets["version"]; ok {
-		ver, err := strconv.ParseInt(string(version), 0, 16)
-		if err != nil {
-			return err
-		}
-		newVersion = ver + 1
+	newVersion, err := nextVersion(secrets)
+	if err != nil {
+		return err
 	}
 	val["version"] = newVersion
 
@@ -107,9 +105,9 @@ func (ss *Service) SetEntry(appName, scope, name string, value interface{}) erro
 	if name == "version" {
 		ss.ds[appName][name] = value
 	} else {
-		str, ok := value.(string)
-		if !ok {
-			return fmt.Errorf("invalid value for %s, must be a string: %v", name, value)
+		str, err := types.EncodeValue(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %s", name, err)
 		}
 		encrypted, err := ss.encryptor.Encrypt(str, appName)
 		if err != nil {
@@ -122,18 +120,30 @@ func (ss *Service) SetEntry(appName, scope, name string, value interface{}) erro
 	return nil
 }
 
+// SetEntries encrypts all the values at once, so batching encryptors are called only once
 func (ss *Service) SetEntries(appName, scope string, data map[string]interface{}) error {
+	values := make(map[string]interface{}, len(data))
 	for k, v := range data {
-		err := ss.SetEntry(appName, scope, k, v)
-		if err != nil {
-			return err
+		if k == "version" {
+			ss.ds[appName][k] = v
+		} else {
+			values[k] = v
 		}
 	}
+
+	encrypted, err := types.EncryptEntries(ss.encryptor, values, appName)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range encrypted {
+		ss.ds[appName][k] = v
+	}
 	return nil
 }
 
 func (ss *Service) GetEntry(appName, scope, name string) (interface{}, error) {
-	// Since app secret only supports strings, return a decrypted string
+	// Values are stored as encrypted strings, return the decrypted value
 	appSecrets, ok := ss.ds[appName]
 	if !ok {
 		return nil, fmt.Errorf("app '%s' is not loaded", appName)
@@ -147,7 +157,7 @@ func (ss *Service) GetEntry(appName, scope, name string) (interface{}, error) {
 
 		str, ok := rawValue.(string)
 		if !ok {
-			return nil, fmt.Errorf("invalid value for %s, must be a string: %v", name, rawValue)
+			return nil, fmt.Errorf("invalid value for %s, must be an encrypted string, got %T", name, rawValue)
 		}
 
 		decrypted, err := ss.encryptor.Decrypt(str, appName)
@@ -155,21 +165,28 @@ func (ss *Service) GetEntry(appName, scope, name string) (interface{}, error) {
 			return nil, err
 		}
 
-		return decrypted, nil
+		return types.DecodeValue(decrypted)
 	}
 
 	return ss.ds[appName][name], nil
 }
 
 func (ss *Service) GetEntries(appName, scope string) (map[string]interface{}, error) {
-	res := make(map[string]interface{})
-	for k := range ss.ds[appName] {
-		val, err := ss.GetEntry(appName, scope, k)
-		if err != nil {
-			return nil, err
+	// Decrypt all the values at once, so batching encryptors are called only once
+	encrypted := make(map[string]interface{})
+	for k, v := range ss.ds[appName] {
+		if k != "version" {
+			encrypted[k] = v
 		}
+	}
 
-		res[k] = val
+	res, err := types.DecryptEntries(ss.encryptor, encrypted, appName)
+	if err != nil {
+		return nil, err
+	}
+
+	if version, ok := ss.ds[appName]["version"]; ok {
+		res["version"] = version
 	}
 	return res, nil
 }
@@ -238,6 +255,31 @@ func (ss *Service) GetCurrentVersion(appName, scope string) (int64, error) {
 	return res, nil
 }
 
+// GetNextVersion returns the version the next write gives to the secret of the app
+func (ss *Service) GetNextVersion(appName, scope string) (int64, error) {
+	secrets, err := ss.client.ReadSecret(secretName(appName), toDashCase(ss.deploymentID))
+	if err != nil && !errors.IsNotFound(err) {
+		return 0, fmt.Errorf("failed to check for an existing value in the kubernetes: %s", err)
+	}
+
+	return nextVersion(secrets)
+}
+
+// nextVersion increments the version of an existing secret, a new secret gets version 0
+func nextVersion(secrets map[string][]byte) (int64, error) {
+	version, ok := secrets["version"]
+	if !ok {
+		return 0, nil
+	}
+
+	ver, err := strconv.ParseInt(string(version), 0, 16)
+	if err != nil {
+		return 0, err
+	}
+
+	return ver + 1, nil
+}
+
 func (ss *Service) GetLatestVersion(appName, scope string) (int64, error) {
 	secretName := secretName(appName)
 
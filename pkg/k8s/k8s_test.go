@@ -1,11 +1,13 @@
 package k8s
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"testing"
 
 	"github.com/openware/kaigara/pkg/encryptor/aes"
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
 	"github.com/openware/kaigara/pkg/encryptor/plaintext"
 	"github.com/openware/kaigara/pkg/encryptor/types"
 	"github.com/openware/pkg/kube"
@@ -179,7 +181,7 @@ func TestSetEntry(t *testing.T) {
 				if isEncoded {
 					assert.NotEqual(t, string(data[key]), val)
 				} else {
-					assert.Equal(t, string(data[key]), val)
+					assert.Equal(t, envelope.Seal(envelope.AlgPlaintext, "", string(data[key])), val)
 				}
 			}
 		}
@@ -361,3 +363,132 @@ func TestGetCurrentVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestGetNextVersion(t *testing.T) {
+	ss, err := NewService(deploymentID, NewMockClient(), encryptors["aes"])
+	assert.NoError(t, err)
+
+	for _, expected := range []int64{0, 1, 2} {
+		next, err := ss.GetNextVersion("finex", "secret")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, next)
+
+		assert.NoError(t, ss.Read("finex", "secret"))
+		assert.NoError(t, ss.Write("finex", "secret"))
+
+		version, err := ss.GetCurrentVersion("finex", "secret")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, version)
+	}
+}
+
+func TestMixedScopes(t *testing.T) {
+	aesEncrypt := encryptors["aes"]
+	client := NewMockClient()
+
+	ss, err := NewService(deploymentID, client, aesEncrypt)
+	assert.NoError(t, err)
+
+	// Public and secret values share the secret of the app, they're all encrypted
+	assert.NoError(t, ss.Read("finex", "public"))
+	assert.NoError(t, ss.SetEntry("finex", "public", "public_key", "public_value"))
+	assert.NoError(t, ss.Write("finex", "public"))
+	assert.NoError(t, ss.Read("finex", "secret"))
+	assert.NoError(t, ss.SetEntries("finex", "secret", map[string]interface{}{"secret_key": "secret_value", "secret_number": json.Number("42")}))
+	assert.NoError(t, ss.Write("finex", "secret"))
+
+	stored, err := client.ReadSecret(secretName("finex"), deploymentID)
+	assert.NoError(t, err)
+	for _, key := range []string{"public_key", "secret_key", "secret_number"} {
+		_, ok := envelope.Parse(string(stored[key]))
+		assert.True(t, ok, key)
+	}
+
+	reader, err := NewService(deploymentID, client, aesEncrypt)
+	assert.NoError(t, err)
+	for _, scope := range []string{"public", "secret"} {
+		assert.NoError(t, reader.Read("finex", scope))
+		entries, err := reader.GetEntries("finex", scope)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"public_key":    "public_value",
+			"secret_key":    "secret_value",
+			"secret_number": json.Number("42"),
+			"version":       int64(1),
+		}, entries)
+
+		value, err := reader.GetEntry("finex", scope, "public_key")
+		assert.NoError(t, err)
+		assert.Equal(t, "public_value", value)
+	}
+}
+
+func TestSetGetEntryComposite(t *testing.T) {
+	values := map[string]interface{}{
+		"key_map":    map[string]interface{}{"key4": "value4"},
+		"key_array":  []interface{}{"value2", "value3"},
+		"key_number": json.Number("1337"),
+		"key_large":  json.Number("9007199254740993"),
+		"key_bool":   true,
+	}
+
+	for _, encryptor := range encryptors {
+		ss, err := NewService(deploymentID, NewMockClient(), encryptor)
+		assert.NoError(t, err)
+
+		err = ss.Read("finex", "secret")
+		assert.NoError(t, err)
+
+		err = ss.SetEntries("finex", "secret", values)
+		assert.NoError(t, err)
+
+		err = ss.Write("finex", "secret")
+		assert.NoError(t, err)
+
+		err = ss.Read("finex", "secret")
+		assert.NoError(t, err)
+
+		for key, val := range values {
+			entry, err := ss.GetEntry("finex", "secret", key)
+			assert.NoError(t, err)
+			assert.Equal(t, val, entry)
+		}
+	}
+}
+
+// batchEncryptor counts the batches sent to the encryptor
+type batchEncryptor struct {
+	types.Encryptor
+	encryptBatches int
+	decryptBatches int
+}
+
+func (be *batchEncryptor) EncryptBatch(plaintexts []string, appName string) ([]string, error) {
+	be.encryptBatches++
+	return types.EncryptAll(be.Encryptor, plaintexts, appName)
+}
+
+func (be *batchEncryptor) DecryptBatch(ciphertexts []string, appName string) ([]string, error) {
+	be.decryptBatches++
+	return types.DecryptAll(be.Encryptor, ciphertexts, appName)
+}
+
+func TestSetGetEntriesBatch(t *testing.T) {
+	encryptor := &batchEncryptor{Encryptor: encryptors["aes"]}
+	ss, err := NewService(deploymentID, NewMockClient(), encryptor)
+	assert.NoError(t, err)
+
+	values := map[string]interface{}{"key1": "value1", "key2": "value2", "key3": json.Number("3")}
+	assert.NoError(t, ss.Read("finex", "secret"))
+	assert.NoError(t, ss.SetEntries("finex", "secret", values))
+	assert.NoError(t, ss.Write("finex", "secret"))
+	assert.Equal(t, 1, encryptor.encryptBatches)
+
+	assert.NoError(t, ss.Read("finex", "secret"))
+	entries, err := ss.GetEntries("finex", "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, encryptor.decryptBatches)
+
+	values["version"] = int64(0)
+	assert.Equal(t, values, entries)
+}
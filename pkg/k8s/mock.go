@@ -1,6 +1,7 @@
 package k8s
 
 import (
+	"github.com/openware/kaigara/pkg/encryptor/aes"
 	"github.com/openware/pkg/kube"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -12,6 +13,9 @@ const (
 	mockNamespace = "odax"
 )
 
+// mockKey is the AES key of the services returned by NewMockService
+var mockKey = []byte("0123456789abcdef")
+
 func MockSecret(name, namespace string, data map[string][]byte) *corev1.Secret {
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -31,3 +35,19 @@ func NewMockClient(obj ...runtime.Object) *kube.K8sClient {
 
 	return client
 }
+
+// NewMockService returns a service of the odax deployment on the client, its values are encrypted with AES
+// as in production, so tests using it go through the encryption of the values
+func NewMockService(client *kube.K8sClient) *Service {
+	encryptor, err := aes.NewAESEncryptor(mockKey)
+	if err != nil {
+		panic(err)
+	}
+
+	ss, err := NewService(mockNamespace, client, encryptor)
+	if err != nil {
+		panic(err)
+	}
+
+	return ss
+}
@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reservedKeys are the keys of JSON lines which fields can't override, such fields get a trailing underscore
+var reservedKeys = map[string]bool{"time": true, "level": true, "logger": true, "msg": true}
+
+// textLine formats a line like "[Kaigara] 2022/07/22 15:52:57 INF: msg key=value"
+func textLine(now time.Time, level Level, name, msg string, fields []Field) []byte {
+	var buf bytes.Buffer
+	if name != "" {
+		fmt.Fprintf(&buf, "[%s] ", name)
+	}
+	fmt.Fprintf(&buf, "%s %s: %s", now.Format("2006/01/02 15:04:05"), levelPrefixes[level], strings.TrimSuffix(msg, "\n"))
+
+	for _, f := range fields {
+		buf.WriteByte(' ')
+		buf.WriteString(f.Key)
+		buf.WriteByte('=')
+		buf.WriteString(textValue(f.Value))
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes()
+}
+
+// textValue formats a value of a text line, strings are quoted if they hold spaces, quotes or equal signs
+func textValue(v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// jsonLine formats a line like {"time":"2022-07-22T15:52:57.123Z","level":"info","logger":"kaigara","msg":"msg","key":"value"}
+func jsonLine(now time.Time, level Level, name, msg string, fields []Field) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSON(&buf, now.UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(&buf, level.String())
+	if name != "" {
+		buf.WriteString(`,"logger":`)
+		writeJSON(&buf, strings.ToLower(name))
+	}
+	buf.WriteString(`,"msg":`)
+	writeJSON(&buf, strings.TrimSuffix(msg, "\n"))
+
+	for _, f := range fields {
+		key := f.Key
+		if reservedKeys[key] {
+			key += "_"
+		}
+		buf.WriteByte(',')
+		writeJSON(&buf, key)
+		buf.WriteByte(':')
+		writeJSON(&buf, f.Value)
+	}
+	buf.WriteString("}\n")
+
+	return buf.Bytes()
+}
+
+// writeJSON writes the JSON encoding of the value, or of its string representation if it can't be encoded
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		raw, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(raw)
+}
+
+// plainValue converts errors, durations, times and stringers to strings, other values are kept
+func plainValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	return v
+}
@@ -0,0 +1,36 @@
+package logger
+
+// KeyValue writes with the default logger the lines of the loggers taking the fields as key and value pairs,
+// like the one of the encryptors and the storage drivers
+type KeyValue struct{}
+
+func (KeyValue) Debug(msg string, keysAndValues ...interface{}) {
+	Default().log(DebugLevel, msg, pairs(keysAndValues))
+}
+
+func (KeyValue) Info(msg string, keysAndValues ...interface{}) {
+	Default().log(InfoLevel, msg, pairs(keysAndValues))
+}
+
+func (KeyValue) Warn(msg string, keysAndValues ...interface{}) {
+	Default().log(WarnLevel, msg, pairs(keysAndValues))
+}
+
+func (KeyValue) Error(msg string, keysAndValues ...interface{}) {
+	Default().log(ErrorLevel, msg, pairs(keysAndValues))
+}
+
+// pairs returns the fields of the key and value pairs, errors are written as their message
+func pairs(keysAndValues []interface{}) []Field {
+	fields := make([]Field, 0, len(keysAndValues)/2)
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		key, _ := keysAndValues[i].(string)
+		if err, ok := keysAndValues[i+1].(error); ok {
+			fields = append(fields, F(key, err.Error()))
+			continue
+		}
+		fields = append(fields, F(key, keysAndValues[i+1]))
+	}
+
+	return fields
+}
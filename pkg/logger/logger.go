@@ -0,0 +1,217 @@
+// Package logger is the leveled and structured logger of kai, kaigara and the storage drivers.
+//
+// Lines are written as text or as JSON objects, with the fields of the logger and of the call. Secret values are never
+// written: fields named like secrets are redacted, and so are the values registered with Redact wherever they appear.
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log line
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	// FatalLevel is logged right before the program exits, the logger doesn't exit by itself
+	FatalLevel
+)
+
+var levelNames = []string{"debug", "info", "warn", "error", "fatal"}
+
+// levelPrefixes are the prefixes of the text format, as written by kaigara before structured logs
+var levelPrefixes = []string{"DBG", "INF", "WRN", "ERR", "FTL"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel returns the level named debug, info, warn or error.
+// Numeric levels, which only set the SQL driver logs in former versions, are read as info, and 4 as debug.
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	if num, err := strconv.Atoi(name); err == nil && num >= 1 && num <= 4 {
+		if num == 4 {
+			return DebugLevel, nil
+		}
+		return InfoLevel, nil
+	}
+
+	for l, levelName := range levelNames[:FatalLevel] {
+		if name == levelName {
+			return Level(l), nil
+		}
+	}
+
+	return InfoLevel, fmt.Errorf("log level '%s' is not supported, use debug, info, warn or error", name)
+}
+
+// Format is the format of the log lines
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the format named text or json
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(name))); format {
+	case FormatText, FormatJSON:
+		return format, nil
+	default:
+		return FormatText, fmt.Errorf("log format '%s' is not supported, use text or json", name)
+	}
+}
+
+// Field is a key and a value given as context of a log line
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a field of a log line
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Err returns the error field of a log line
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error"}
+	}
+
+	return Field{Key: "error", Value: err.Error()}
+}
+
+// output is shared by a logger and the loggers derived from it with With
+type output struct {
+	mu     sync.Mutex
+	w      io.Writer
+	name   string
+	level  Level
+	format Format
+	now    func() time.Time
+}
+
+// Logger writes leveled lines with fields
+type Logger struct {
+	out    *output
+	fields []Field
+}
+
+// New returns a logger writing the lines of level and above to w in format, name prefixes text lines
+func New(w io.Writer, name string, level Level, format Format) *Logger {
+	return &Logger{out: &output{w: w, name: name, level: level, format: format, now: time.Now}}
+}
+
+// With returns a logger adding the fields to each line
+func (l *Logger) With(fields ...Field) *Logger {
+	return &Logger{out: l.out, fields: append(append([]Field{}, l.fields...), fields...)}
+}
+
+// Enabled tells if the lines of level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.out.level
+}
+
+func (l *Logger) Debug(msg string, fields ...Field) {
+	l.log(DebugLevel, msg, fields)
+}
+
+func (l *Logger) Info(msg string, fields ...Field) {
+	l.log(InfoLevel, msg, fields)
+}
+
+func (l *Logger) Warn(msg string, fields ...Field) {
+	l.log(WarnLevel, msg, fields)
+}
+
+func (l *Logger) Error(msg string, fields ...Field) {
+	l.log(ErrorLevel, msg, fields)
+}
+
+// Fatal logs at fatal level, the caller is expected to exit
+func (l *Logger) Fatal(msg string, fields ...Field) {
+	l.log(FatalLevel, msg, fields)
+}
+
+func (l *Logger) log(level Level, msg string, fields []Field) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	all := append(append([]Field{}, l.fields...), fields...)
+	for i, f := range all {
+		all[i].Value = redactField(f)
+	}
+	msg = redactString(msg)
+
+	var line []byte
+	if l.out.format == FormatJSON {
+		line = jsonLine(l.out.now(), level, l.out.name, msg, all)
+	} else {
+		line = textLine(l.out.now(), level, l.out.name, msg, all)
+	}
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(line)
+}
+
+var (
+	mu  sync.RWMutex
+	std = New(os.Stderr, "Kaigara", InfoLevel, FormatText)
+)
+
+// Default returns the logger used by the package functions
+func Default() *Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return std
+}
+
+// SetDefault replaces the logger used by the package functions
+func SetDefault(l *Logger) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	std = l
+}
+
+// With returns the default logger adding the fields to each line
+func With(fields ...Field) *Logger {
+	return Default().With(fields...)
+}
+
+func Debug(msg string, fields ...Field) {
+	Default().log(DebugLevel, msg, fields)
+}
+
+func Info(msg string, fields ...Field) {
+	Default().log(InfoLevel, msg, fields)
+}
+
+func Warn(msg string, fields ...Field) {
+	Default().log(WarnLevel, msg, fields)
+}
+
+func Error(msg string, fields ...Field) {
+	Default().log(ErrorLevel, msg, fields)
+}
+
+// Fatal logs at fatal level with the default logger, the caller is expected to exit
+func Fatal(msg string, fields ...Field) {
+	Default().log(FatalLevel, msg, fields)
+}
@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLogger(level Level, format Format) (*Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	l := New(&buf, "Kaigara", level, format)
+	l.out.now = func() time.Time { return time.Date(2022, 7, 22, 15, 52, 57, 0, time.UTC) }
+
+	return l, &buf
+}
+
+func TestText(t *testing.T) {
+	l, buf := newTestLogger(InfoLevel, FormatText)
+
+	l.With(F("deployment_id", "odax")).Info("restarting process", F("app", "finex"), F("version", 3), F("reason", "secrets updated"), Err(errors.New("exit status 1")))
+	l.Debug("hidden")
+
+	assert.Equal(t, `[Kaigara] 2022/07/22 15:52:57 INF: restarting process deployment_id=odax app=finex version=3 reason="secrets updated" error="exit status 1"`+"\n", buf.String())
+}
+
+func TestJSON(t *testing.T) {
+	l, buf := newTestLogger(DebugLevel, FormatJSON)
+
+	l.With(F("deployment_id", "odax")).Warn("restarting process\n", F("delay", 1500*time.Millisecond), F("msg", "field"), F("vars", []string{"FINEX_HOST"}))
+	assert.Equal(t, `{"time":"2022-07-22T15:52:57Z","level":"warn","logger":"kaigara","msg":"restarting process","deployment_id":"odax","delay":"1.5s","msg_":"field","vars":["FINEX_HOST"]}`+"\n", buf.String())
+
+	var line map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+}
+
+func TestRedact(t *testing.T) {
+	l, buf := newTestLogger(InfoLevel, FormatText)
+
+	l.Info("entry set", F("entry", "finex_database_password"), F("value", "changeme"), F("database_password", "changeme"))
+	assert.Contains(t, buf.String(), "entry=finex_database_password value=[REDACTED] database_password=[REDACTED]")
+
+	Redact("s3cr3t-passw0rd", "abc")
+	buf.Reset()
+	l.Error("failed to connect with s3cr3t-passw0rd", Err(errors.New("password s3cr3t-passw0rd is invalid")), F("vars", []string{"A=s3cr3t-passw0rd"}), F("short", "abc"))
+	assert.Equal(t, `[Kaigara] 2022/07/22 15:52:57 ERR: failed to connect with [REDACTED] error="password [REDACTED] is invalid" vars=[A=[REDACTED]] short=abc`+"\n", buf.String())
+	assert.Contains(t, RedactedValues(), "s3cr3t-passw0rd")
+	assert.NotContains(t, RedactedValues(), "abc")
+
+	defer SetMinRedactLength(DefaultMinRedactLength)
+	Redact("s3cr3", "abcd-passw0rd")
+	SetMinRedactLength(3)
+	Redact("xyz")
+	assert.NotContains(t, RedactedValues(), "s3cr3")
+	assert.Contains(t, RedactedValues(), "abcd-passw0rd")
+	assert.Contains(t, RedactedValues(), "xyz")
+}
+
+func TestParseLevel(t *testing.T) {
+	for name, level := range map[string]Level{"debug": DebugLevel, "INFO": InfoLevel, "warn": WarnLevel, "error": ErrorLevel, "1": InfoLevel, "4": DebugLevel} {
+		parsed, err := ParseLevel(name)
+		assert.NoError(t, err)
+		assert.Equal(t, level, parsed)
+	}
+
+	_, err := ParseLevel("fatal")
+	assert.Error(t, err)
+
+	_, err = ParseFormat("xml")
+	assert.Error(t, err)
+}
+
+func TestKeyValue(t *testing.T) {
+	l, buf := newTestLogger(InfoLevel, FormatText)
+	prev := Default()
+	SetDefault(l)
+	defer SetDefault(prev)
+
+	KeyValue{}.Error("Vault token renewal failed", "app", "finex", "error", errors.New("permission denied"))
+	KeyValue{}.Debug("hidden")
+
+	assert.Equal(t, `[Kaigara] 2022/07/22 15:52:57 ERR: Vault token renewal failed app=finex error="permission denied"`+"\n", buf.String())
+}
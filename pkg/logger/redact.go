@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the secret values in log lines
+const Redacted = "[REDACTED]"
+
+// DefaultMinRedactLength is the default minimum length of the values registered with Redact,
+// shorter ones would redact common words and numbers
+const DefaultMinRedactLength = 6
+
+// secretKeys are the names of the fields always redacted, alone or as suffix like database_password
+var secretKeys = []string{"value", "values", "password", "secret", "token", "content", "plaintext", "ciphertext"}
+
+var redaction = struct {
+	sync.RWMutex
+	minLength int
+	values    map[string]bool
+	replacer  *strings.Replacer
+}{minLength: DefaultMinRedactLength, values: map[string]bool{}}
+
+// SetMinRedactLength sets the minimum length of the values registered from now on with Redact,
+// zero or less restores DefaultMinRedactLength
+func SetMinRedactLength(length int) {
+	redaction.Lock()
+	defer redaction.Unlock()
+
+	if length <= 0 {
+		length = DefaultMinRedactLength
+	}
+	redaction.minLength = length
+}
+
+// Redact registers secret values, they're replaced by Redacted wherever they appear in the messages and the fields
+func Redact(values ...string) {
+	redaction.Lock()
+	defer redaction.Unlock()
+
+	added := false
+	for _, v := range values {
+		if len(v) >= redaction.minLength && !redaction.values[v] {
+			redaction.values[v] = true
+			added = true
+		}
+	}
+	if !added {
+		return
+	}
+
+	// Longer values are replaced first, so a value containing another one isn't partly written
+	sorted := make([]string, 0, len(redaction.values))
+	for v := range redaction.values {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	pairs := make([]string, 0, 2*len(sorted))
+	for _, v := range sorted {
+		pairs = append(pairs, v, Redacted)
+	}
+	redaction.replacer = strings.NewReplacer(pairs...)
+}
+
+// RedactedValues returns the registered secret values, sorted
+func RedactedValues() []string {
+	redaction.RLock()
+	defer redaction.RUnlock()
+
+	values := make([]string, 0, len(redaction.values))
+	for v := range redaction.values {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	return values
+}
+
+// redactString replaces the registered secret values in s
+func redactString(s string) string {
+	redaction.RLock()
+	defer redaction.RUnlock()
+
+	if redaction.replacer == nil {
+		return s
+	}
+
+	return redaction.replacer.Replace(s)
+}
+
+// redactField returns the value of the field to log, redacted if the field is named like a secret
+func redactField(f Field) interface{} {
+	if f.Value == nil {
+		return nil
+	}
+	if IsSecretKey(f.Key) {
+		return Redacted
+	}
+
+	switch v := plainValue(f.Value).(type) {
+	case string:
+		return redactString(v)
+	case []string:
+		redacted := make([]string, len(v))
+		for i, s := range v {
+			redacted[i] = redactString(s)
+		}
+		return redacted
+	default:
+		return v
+	}
+}
+
+// IsSecretKey tells if a field or a stored key named key holds a secret, alone or as suffix like database_password
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if key == secret || strings.HasSuffix(key, "_"+secret) {
+			return true
+		}
+	}
+
+	return false
+}
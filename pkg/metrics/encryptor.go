@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"io"
+	"time"
+
+	enc "github.com/openware/kaigara/pkg/encryptor/types"
+)
+
+// Encryptor wraps an encryptor to measure the latency and the errors of its calls,
+// batches are passed to the encryptor if it supports them
+type Encryptor struct {
+	encryptor enc.Encryptor
+}
+
+// NewEncryptor instantiates a measured encryptor around the encryptor
+func NewEncryptor(encryptor enc.Encryptor) *Encryptor {
+	return &Encryptor{encryptor: encryptor}
+}
+
+// Close closes the measured encryptor if it holds resources
+func (e *Encryptor) Close() error {
+	if closer, ok := e.encryptor.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+func (e *Encryptor) Encrypt(plaintext, appName string) (string, error) {
+	start := time.Now()
+	ciphertext, err := e.encryptor.Encrypt(plaintext, appName)
+	observe(encryptorDuration, encryptorErrors, "encrypt", start, err)
+
+	return ciphertext, err
+}
+
+func (e *Encryptor) Decrypt(ciphertext, appName string) (string, error) {
+	start := time.Now()
+	plaintext, err := e.encryptor.Decrypt(ciphertext, appName)
+	observe(encryptorDuration, encryptorErrors, "decrypt", start, err)
+
+	return plaintext, err
+}
+
+func (e *Encryptor) EncryptBatch(plaintexts []string, appName string) ([]string, error) {
+	batch, ok := e.encryptor.(enc.BatchEncryptor)
+	if !ok {
+		ciphertexts := make([]string, len(plaintexts))
+		for i, plaintext := range plaintexts {
+			ciphertext, err := e.Encrypt(plaintext, appName)
+			if err != nil {
+				return nil, err
+			}
+			ciphertexts[i] = ciphertext
+		}
+
+		return ciphertexts, nil
+	}
+
+	start := time.Now()
+	ciphertexts, err := batch.EncryptBatch(plaintexts, appName)
+	observe(encryptorDuration, encryptorErrors, "encrypt_batch", start, err)
+
+	return ciphertexts, err
+}
+
+func (e *Encryptor) DecryptBatch(ciphertexts []string, appName string) ([]string, error) {
+	batch, ok := e.encryptor.(enc.BatchEncryptor)
+	if !ok {
+		plaintexts := make([]string, len(ciphertexts))
+		for i, ciphertext := range ciphertexts {
+			plaintext, err := e.Decrypt(ciphertext, appName)
+			if err != nil {
+				return nil, err
+			}
+			plaintexts[i] = plaintext
+		}
+
+		return plaintexts, nil
+	}
+
+	start := time.Now()
+	plaintexts, err := batch.DecryptBatch(ciphertexts, appName)
+	observe(encryptorDuration, encryptorErrors, "decrypt_batch", start, err)
+
+	return plaintexts, err
+}
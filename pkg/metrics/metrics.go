@@ -0,0 +1,200 @@
+// Package metrics exposes Prometheus metrics and a health check about the kaigara wrapper,
+// its secret storage and encryptor calls, the secrets polling and the supervised processes.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Registry holds all kaigara metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	secretsVersion = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kaigara_secrets_version",
+		Help: "Version of the secrets loaded per app and scope.",
+	}, []string{"app", "scope"})
+
+	restarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kaigara_restarts_total",
+		Help: "Number of restarts of the processes per reason.",
+	}, []string{"reason"})
+
+	storageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "kaigara_storage_duration_seconds",
+		Help: "Latency of the secret storage calls per operation.",
+	}, []string{"operation"})
+
+	storageErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kaigara_storage_errors_total",
+		Help: "Number of failed secret storage calls per operation.",
+	}, []string{"operation"})
+
+	encryptorDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "kaigara_encryptor_duration_seconds",
+		Help: "Latency of the encryptor calls per operation.",
+	}, []string{"operation"})
+
+	encryptorErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kaigara_encryptor_errors_total",
+		Help: "Number of failed encryptor calls per operation.",
+	}, []string{"operation"})
+
+	pollErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kaigara_poll_errors_total",
+		Help: "Number of failed polls of the secrets versions.",
+	})
+
+	snapshotInUse = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kaigara_snapshot_in_use",
+		Help: "Whether the processes run with the secrets of the local snapshot because the secret storage is unreachable.",
+	})
+
+	processPID = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kaigara_process_pid",
+		Help: "PID of the running processes.",
+	}, []string{"process"})
+)
+
+var (
+	mu        sync.Mutex
+	started   = time.Now()
+	lastPoll  time.Time
+	snapshot  bool
+	processes = map[string]time.Time{}
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		secretsVersion,
+		restarts,
+		storageDuration,
+		storageErrors,
+		encryptorDuration,
+		encryptorErrors,
+		pollErrors,
+		snapshotInUse,
+		processPID,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "kaigara_seconds_since_last_poll",
+			Help: "Time since the last successful poll of the secrets versions, or since kaigara started if none succeeded.",
+		}, func() float64 {
+			return SinceLastPoll().Seconds()
+		}),
+		uptimeCollector{},
+	)
+}
+
+// SetSecretsVersion records the version of the secrets loaded for the app and scope
+func SetSecretsVersion(appName, scope string, version int64) {
+	secretsVersion.WithLabelValues(appName, scope).Set(float64(version))
+}
+
+// Restarted counts a restart of the processes for the reason
+func Restarted(reason string) {
+	restarts.WithLabelValues(reason).Inc()
+}
+
+// PollSucceeded records a successful poll of the secrets versions
+func PollSucceeded() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	lastPoll = time.Now()
+}
+
+// PollFailed counts a failed poll of the secrets versions
+func PollFailed() {
+	pollErrors.Inc()
+}
+
+// SinceLastPoll returns the time since the last successful poll, or since kaigara started if none succeeded
+func SinceLastPoll() time.Duration {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if lastPoll.IsZero() {
+		return time.Since(started)
+	}
+
+	return time.Since(lastPoll)
+}
+
+// SnapshotInUse records whether the processes run with the secrets of the local snapshot
+func SnapshotInUse(inUse bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	snapshot = inUse
+	if inUse {
+		snapshotInUse.Set(1)
+	} else {
+		snapshotInUse.Set(0)
+	}
+}
+
+// UsingSnapshot tells if the processes run with the secrets of the local snapshot
+func UsingSnapshot() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return snapshot
+}
+
+// ProcessStarted records the PID and the start time of a process
+func ProcessStarted(name string, pid int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	processes[name] = time.Now()
+	processPID.WithLabelValues(name).Set(float64(pid))
+}
+
+// ProcessExited forgets a process which exited
+func ProcessExited(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	delete(processes, name)
+	processPID.DeleteLabelValues(name)
+}
+
+// RunningProcesses returns the number of running processes
+func RunningProcesses() int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return len(processes)
+}
+
+// observe records the latency and the error of a call
+func observe(duration *prometheus.HistogramVec, errors *prometheus.CounterVec, operation string, start time.Time, err error) {
+	duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		errors.WithLabelValues(operation).Inc()
+	}
+}
+
+var uptimeDesc = prometheus.NewDesc("kaigara_process_uptime_seconds", "Time since the running processes started.", []string{"process"}, nil)
+
+// uptimeCollector reports the uptime of the running processes at scrape time
+type uptimeCollector struct{}
+
+func (uptimeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- uptimeDesc
+}
+
+func (uptimeCollector) Collect(ch chan<- prometheus.Metric) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for name, start := range processes {
+		ch <- prometheus.MustNewConstMetric(uptimeDesc, prometheus.GaugeValue, time.Since(start).Seconds(), name)
+	}
+}
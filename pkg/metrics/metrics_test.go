@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/encryptor/plaintext"
+	"github.com/openware/kaigara/types"
+)
+
+type failingStorage struct {
+	types.Storage
+}
+
+func (failingStorage) Read(appName, scope string) error {
+	return errors.New("storage is unreachable")
+}
+
+func (failingStorage) GetLatestVersion(appName, scope string) (int64, error) {
+	return 3, nil
+}
+
+func TestStorage(t *testing.T) {
+	ss := NewStorage(failingStorage{})
+
+	assert.Error(t, ss.Read("finex", "secret"))
+	assert.Error(t, ss.Read("finex", "secret"))
+	version, err := ss.GetLatestVersion("finex", "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), version)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(storageErrors.WithLabelValues("read")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(storageErrors.WithLabelValues("get_latest_version")))
+	assert.Equal(t, 2, testutil.CollectAndCount(storageDuration))
+}
+
+func TestEncryptor(t *testing.T) {
+	encryptor := NewEncryptor(plaintext.NewPlaintextEncryptor())
+
+	ciphertexts, err := encryptor.EncryptBatch([]string{"a", "b"}, "finex")
+	assert.NoError(t, err)
+
+	plaintexts, err := encryptor.DecryptBatch(ciphertexts, "finex")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, plaintexts)
+
+	// The plaintext encryptor doesn't support batches, so values are measured one by one
+	assert.Equal(t, 2, testutil.CollectAndCount(encryptorDuration))
+	assert.Equal(t, float64(0), testutil.ToFloat64(encryptorErrors.WithLabelValues("decrypt")))
+}
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(Handler(time.Minute))
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		res, err := http.Get(server.URL + path)
+		assert.NoError(t, err)
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		return res.StatusCode, string(body)
+	}
+
+	code, body := get("/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "no process is running")
+
+	ProcessStarted("web", 42)
+	defer ProcessExited("web")
+	PollSucceeded()
+	SetSecretsVersion("finex", "secret", 7)
+	Restarted("secrets_updated")
+
+	code, body = get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok\n", body)
+
+	code, body = get("/metrics")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `kaigara_secrets_version{app="finex",scope="secret"} 7`)
+	assert.Contains(t, body, `kaigara_restarts_total{reason="secrets_updated"} 1`)
+	assert.Contains(t, body, `kaigara_process_pid{process="web"} 42`)
+	assert.Contains(t, body, `kaigara_process_uptime_seconds{process="web"}`)
+	assert.Contains(t, body, "kaigara_seconds_since_last_poll")
+}
+
+func TestHealthStalePoll(t *testing.T) {
+	ProcessStarted("web", 42)
+	defer ProcessExited("web")
+
+	mu.Lock()
+	lastPoll = time.Now().Add(-time.Hour)
+	mu.Unlock()
+
+	err := healthy(time.Minute)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "weren't polled")
+
+	// Polls fail while the storage is unreachable and the processes run with the snapshot
+	SnapshotInUse(true)
+	defer SnapshotInUse(false)
+	assert.NoError(t, healthy(time.Minute))
+	assert.Equal(t, float64(1), testutil.ToFloat64(snapshotInUse))
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler serves the metrics on /metrics and the health check on /healthz.
+// kaigara is healthy while a process is running and the secrets versions were polled within maxPollAge,
+// or the processes run with the secrets of the local snapshot.
+func Handler(maxPollAge time.Duration) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if err := healthy(maxPollAge); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	return mux
+}
+
+// healthy returns an error telling why kaigara isn't healthy
+func healthy(maxPollAge time.Duration) error {
+	if RunningProcesses() == 0 {
+		return fmt.Errorf("no process is running")
+	}
+	if age := SinceLastPoll(); age > maxPollAge && !UsingSnapshot() {
+		return fmt.Errorf("secrets versions weren't polled for %s", age.Round(time.Second))
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/openware/kaigara/types"
+)
+
+// Storage wraps a storage driver to measure the latency and the errors of the calls reaching the storage,
+// in memory calls are passed through
+type Storage struct {
+	types.Storage
+}
+
+// NewStorage instantiates a measured storage around the driver
+func NewStorage(ss types.Storage) *Storage {
+	return &Storage{Storage: ss}
+}
+
+func (s *Storage) Read(appName, scope string) error {
+	start := time.Now()
+	err := s.Storage.Read(appName, scope)
+	observe(storageDuration, storageErrors, "read", start, err)
+
+	return err
+}
+
+func (s *Storage) Write(appName, scope string) error {
+	start := time.Now()
+	err := s.Storage.Write(appName, scope)
+	observe(storageDuration, storageErrors, "write", start, err)
+
+	return err
+}
+
+func (s *Storage) ListAppNames() ([]string, error) {
+	start := time.Now()
+	appNames, err := s.Storage.ListAppNames()
+	observe(storageDuration, storageErrors, "list_app_names", start, err)
+
+	return appNames, err
+}
+
+func (s *Storage) GetLatestVersion(appName, scope string) (int64, error) {
+	start := time.Now()
+	version, err := s.Storage.GetLatestVersion(appName, scope)
+	observe(storageDuration, storageErrors, "get_latest_version", start, err)
+
+	return version, err
+}
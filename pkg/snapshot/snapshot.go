@@ -0,0 +1,94 @@
+// Package snapshot saves the environment and files built from the secret storage to an encrypted local file,
+// so the command can be started from the last known good secrets while the storage is unreachable.
+//
+// The snapshot is JSON encrypted with XChaCha20-Poly1305, which also authenticates it.
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/openware/kaigara/pkg/config"
+	"github.com/openware/kaigara/pkg/encryptor/xchacha"
+	"github.com/openware/kaigara/pkg/env"
+	"github.com/openware/kaigara/pkg/logger"
+)
+
+// KeySize is the required key length in bytes
+const KeySize = xchacha.KeySize
+
+// snapshotApp is given to the encryptor as the app name of the snapshot
+const snapshotApp = "kaigara_snapshot"
+
+// Snapshot is the environment and files of the command saved at a given time.
+// Redacted holds the secret values never logged, they're registered with the logger again when it's loaded.
+type Snapshot struct {
+	SavedAt  time.Time               `json:"saved_at"`
+	Vars     []string                `json:"vars"`
+	Files    map[string]*config.File `json:"files"`
+	Locked   []string                `json:"locked,omitempty"`
+	Redacted []string                `json:"redacted,omitempty"`
+}
+
+// Env returns the environment of the snapshot
+func (s *Snapshot) Env() *config.Env {
+	return &config.Env{Vars: s.Vars, Files: s.Files, Locked: s.Locked}
+}
+
+// Save encrypts the environment with the key and writes it atomically to path, readable only by its owner
+func Save(path string, key []byte, envs *config.Env) error {
+	encryptor, err := xchacha.NewXChaChaEncryptor(key)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(&Snapshot{
+		SavedAt:  time.Now().UTC(),
+		Vars:     envs.Vars,
+		Files:    envs.Files,
+		Locked:   envs.Locked,
+		Redacted: logger.RedactedValues(),
+	})
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := encryptor.Encrypt(string(raw), snapshotApp)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt snapshot: %s", err)
+	}
+
+	return env.WriteFile(&config.File{Path: path, Content: ciphertext, Mode: "0600", Encoding: env.EncodingRaw})
+}
+
+// Load reads the snapshot at path and decrypts it with the key, it fails if it's older than maxAge unless maxAge is 0
+func Load(path string, key []byte, maxAge time.Duration) (*Snapshot, error) {
+	encryptor, err := xchacha.NewXChaChaEncryptor(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %s", err)
+	}
+
+	raw, err := encryptor.Decrypt(string(ciphertext), snapshotApp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt snapshot: %s", err)
+	}
+
+	snap := &Snapshot{}
+	if err := json.Unmarshal([]byte(raw), snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %s", err)
+	}
+
+	if age := time.Since(snap.SavedAt); maxAge > 0 && age > maxAge {
+		return nil, fmt.Errorf("snapshot saved %s ago is older than %s", age.Round(time.Second), maxAge)
+	}
+	logger.Redact(snap.Redacted...)
+
+	return snap, nil
+}
@@ -0,0 +1,78 @@
+package snapshot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/config"
+	"github.com/openware/kaigara/pkg/logger"
+)
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot")
+	key := bytes.Repeat([]byte{1}, KeySize)
+	envs := &config.Env{
+		Vars:  []string{"FINEX_DATABASE_PASSWORD=changeme"},
+		Files: map[string]*config.File{"CONFIG": {Path: "/etc/finex/config.yml", Content: "YXBw", Mode: "0600"}},
+	}
+
+	assert.NoError(t, Save(path, key, envs))
+
+	// The secrets aren't readable from the file
+	raw, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(raw), "changeme")
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	snap, err := Load(path, key, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, envs, snap.Env())
+	assert.WithinDuration(t, time.Now(), snap.SavedAt, time.Minute)
+
+	_, err = Load(path, bytes.Repeat([]byte{2}, KeySize), time.Hour)
+	assert.ErrorContains(t, err, "failed to decrypt snapshot")
+
+	_, err = Load(path, key[:16], time.Hour)
+	assert.Error(t, err)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing"), key, time.Hour)
+	assert.ErrorContains(t, err, "failed to read snapshot")
+}
+
+func TestLoadMaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot")
+	key := bytes.Repeat([]byte{1}, KeySize)
+
+	assert.NoError(t, Save(path, key, &config.Env{Vars: []string{}, Files: map[string]*config.File{}}))
+	time.Sleep(10 * time.Millisecond)
+
+	_, err := Load(path, key, time.Millisecond)
+	assert.ErrorContains(t, err, "is older than 1ms")
+
+	// No maximum age
+	_, err = Load(path, key, 0)
+	assert.NoError(t, err)
+}
+
+func TestSaveLoadRedacted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot")
+	key := bytes.Repeat([]byte{1}, KeySize)
+
+	logger.Redact("s3cr3t-snapshot-pass")
+	assert.NoError(t, Save(path, key, &config.Env{Vars: []string{"FINEX_DATABASE_PASSWORD=s3cr3t-snapshot-pass"}}))
+
+	snap, err := Load(path, key, time.Hour)
+	assert.NoError(t, err)
+	assert.Contains(t, snap.Redacted, "s3cr3t-snapshot-pass")
+}
@@ -0,0 +1,67 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/openware/kaigara/pkg/encryptor/types"
+)
+
+// slowQuery is the duration from which queries are logged as slow
+const slowQuery = 200 * time.Millisecond
+
+// gormLogger writes the gorm logs with the logger of the drivers, without the SQL statements since they hold the stored values
+type gormLogger struct {
+	level gormlogger.LogLevel
+}
+
+// newGormLogger returns a gorm logger writing the logs of level and above, gorm.Info ones are written at debug level
+func newGormLogger(level gormlogger.LogLevel) gormlogger.Interface {
+	return &gormLogger{level: level}
+}
+
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &gormLogger{level: level}
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		types.Log().Debug(fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		types.Log().Warn(fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		types.Log().Error(fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		_, rows := fc()
+		types.Log().Error("SQL query failed", "duration", elapsed, "rows", rows, "error", err)
+	case elapsed > slowQuery && l.level >= gormlogger.Warn:
+		_, rows := fc()
+		types.Log().Warn("slow SQL query", "duration", elapsed, "rows", rows)
+	case l.level >= gormlogger.Info:
+		_, rows := fc()
+		types.Log().Debug("SQL query", "duration", elapsed, "rows", rows)
+	}
+}
@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -11,7 +12,7 @@ import (
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	gormlogger "gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -24,6 +25,7 @@ type Service struct {
 	deploymentID string
 	ds           map[string]map[string]map[string]interface{}
 	encryptor    types.Encryptor
+	policy       types.ScopePolicy
 }
 
 // Data represents per-scope data(configs/secrets) which consists of a JSON field
@@ -56,7 +58,7 @@ func (customName TableNameReplaceable) Replace(name string) string {
 	return name
 }
 
-func NewService(deploymentID string, conf *DatabaseConfig, encryptor types.Encryptor, logLevel int) (*Service, error) {
+func NewService(deploymentID string, conf *DatabaseConfig, encryptor types.Encryptor, policy types.ScopePolicy, logLevel int) (*Service, error) {
 	if conf.Name == "" {
 		conf.Name = "kaigara_" + deploymentID
 	}
@@ -69,11 +71,7 @@ func NewService(deploymentID string, conf *DatabaseConfig, encryptor types.Encry
 	if err != nil {
 		return nil, err
 	}
-	db.Logger = logger.Default.LogMode(logger.LogLevel(logLevel))
-
-	if logLevel > 1 {
-		db = db.Debug()
-	}
+	db.Logger = newGormLogger(gormlogger.LogLevel(logLevel))
 
 	err = db.AutoMigrate(&Data{})
 	if err != nil {
@@ -84,6 +82,7 @@ func NewService(deploymentID string, conf *DatabaseConfig, encryptor types.Encry
 		db:           db,
 		deploymentID: deploymentID,
 		encryptor:    encryptor,
+		policy:       policy,
 	}, nil
 }
 
@@ -166,6 +165,7 @@ func Connect(conf *DatabaseConfig) (*gorm.DB, error) {
 
 	db, err := gorm.Open(dial, &gorm.Config{
 		NamingStrategy: namingStrategy,
+		Logger:         newGormLogger(gormlogger.Warn),
 	})
 	if err != nil {
 		return nil, err
@@ -207,7 +207,10 @@ func (ss *Service) Read(appName, scope string) error {
 	if res.Error != nil && !isNotFound {
 		return fmt.Errorf("failed reading from the DB: %s", res.Error)
 	} else if !isNotFound {
-		if err := json.Unmarshal([]byte(data.Value), &val); err != nil {
+		// Numbers are read as json.Number, like the Vault driver does, so integers above 2^53 keep their value
+		dec := json.NewDecoder(bytes.NewReader(data.Value))
+		dec.UseNumber()
+		if err := dec.Decode(&val); err != nil {
 			return fmt.Errorf("JSON unmarshalling failed: %s", err)
 		}
 
@@ -282,10 +285,10 @@ func (ss *Service) ListEntries(appName, scope string) ([]string, error) {
 }
 
 func (ss *Service) SetEntry(appName, scope, name string, value interface{}) error {
-	if scope == "secret" && name != "version" {
-		str, ok := value.(string)
-		if !ok {
-			return fmt.Errorf("invalid value for %s, must be a string: %v", name, value)
+	if ss.policy.IsEncrypted(scope) && name != "version" {
+		str, err := types.EncodeValue(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %s", name, err)
 		}
 		encrypted, err := ss.encryptor.Encrypt(str, ss.transitKeyName(appName))
 		if err != nil {
@@ -300,24 +303,43 @@ func (ss *Service) SetEntry(appName, scope, name string, value interface{}) erro
 	return nil
 }
 
+// SetEntries encrypts all the values of encrypted scopes at once, so batching encryptors are called only once
 func (ss *Service) SetEntries(appName string, scope string, values map[string]interface{}) error {
+	if !ss.policy.IsEncrypted(scope) {
+		for k, v := range values {
+			ss.ds[appName][scope][k] = v
+		}
+		return nil
+	}
+
+	data := make(map[string]interface{}, len(values))
 	for k, v := range values {
-		err := ss.SetEntry(appName, scope, k, v)
-		if err != nil {
-			return err
+		if k == "version" {
+			ss.ds[appName][scope][k] = v
+		} else {
+			data[k] = v
 		}
 	}
+
+	encrypted, err := types.EncryptEntries(ss.encryptor, data, ss.transitKeyName(appName))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range encrypted {
+		ss.ds[appName][scope][k] = v
+	}
 	return nil
 }
 
 func (ss *Service) GetEntry(appName, scope, name string) (interface{}, error) {
-	// Since secret scope only supports strings, return a decrypted string
+	// Values of encrypted scopes are stored as encrypted strings, return the decrypted value
 	scopeSecrets, ok := ss.ds[appName][scope]
 	if !ok {
 		return nil, fmt.Errorf("scope '%s' is not loaded", scope)
 	}
 
-	if scope == "secret" && name != "version" {
+	if ss.policy.IsEncrypted(scope) && name != "version" {
 		rawValue, ok := scopeSecrets[name]
 		if !ok {
 			return nil, nil
@@ -325,7 +347,7 @@ func (ss *Service) GetEntry(appName, scope, name string) (interface{}, error) {
 
 		str, ok := rawValue.(string)
 		if !ok {
-			return nil, fmt.Errorf("invalid value for %s, must be a string: %v", name, rawValue)
+			return nil, fmt.Errorf("invalid value for %s, must be an encrypted string, got %T", name, rawValue)
 		}
 
 		decrypted, err := ss.encryptor.Decrypt(str, ss.transitKeyName(appName))
@@ -333,13 +355,33 @@ func (ss *Service) GetEntry(appName, scope, name string) (interface{}, error) {
 			return nil, err
 		}
 
-		return decrypted, nil
+		return types.DecodeValue(decrypted)
 	}
 
 	return ss.ds[appName][scope][name], nil
 }
 
 func (ss *Service) GetEntries(appName string, scope string) (map[string]interface{}, error) {
+	// Decrypt all the values of encrypted scopes at once, so batching encryptors are called only once
+	if ss.policy.IsEncrypted(scope) {
+		encrypted := make(map[string]interface{})
+		for k, v := range ss.ds[appName][scope] {
+			if k != "version" {
+				encrypted[k] = v
+			}
+		}
+
+		res, err := types.DecryptEntries(ss.encryptor, encrypted, ss.transitKeyName(appName))
+		if err != nil {
+			return nil, err
+		}
+
+		if version, ok := ss.ds[appName][scope]["version"]; ok {
+			res["version"] = version
+		}
+		return res, nil
+	}
+
 	res := make(map[string]interface{})
 	for k := range ss.ds[appName][scope] {
 		val, err := ss.GetEntry(appName, scope, k)
@@ -381,6 +423,20 @@ func (ss *Service) GetCurrentVersion(appName, scope string) (int64, error) {
 	return res, nil
 }
 
+// GetNextVersion returns the version the next write gives to the scope, a new scope gets version 0
+func (ss *Service) GetNextVersion(appName, scope string) (int64, error) {
+	var data Data
+	res := ss.db.Where("app_name = ? AND scope = ?", appName, scope).First(&data)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if res.Error != nil {
+		return 0, fmt.Errorf("failed to check for an existing value in the DB: %s", res.Error)
+	}
+
+	return data.Version + 1, nil
+}
+
 func (ss *Service) GetLatestVersion(appName, scope string) (int64, error) {
 	var data Data
 	req := ss.db.Where("app_name = ? AND scope = ?", appName, scope).First(&data)
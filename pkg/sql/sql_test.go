@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -23,6 +24,7 @@ var appNames = []string{"barong", "finex", "peatio"}
 var scopes = []string{"private", "public", "secret"}
 var configs map[string]DatabaseConfig
 var encryptors map[string]types.Encryptor
+var policy = types.NewScopePolicy(nil)
 
 func TestMain(m *testing.M) {
 	vaultAddr := os.Getenv("KAIGARA_VAULT_ADDR")
@@ -121,7 +123,7 @@ func TestSetEntry(t *testing.T) {
 	for testEncName, encryptor := range encryptors {
 		for testDbName, conf := range configs {
 			t.Run(testDbName+"-"+testEncName, func(t *testing.T) {
-				ss, err := NewService(deploymentID, &conf, encryptor, testLogLevel)
+				ss, err := NewService(deploymentID, &conf, encryptor, policy, testLogLevel)
 				assert.NoError(t, err)
 
 				for _, scope := range scopes {
@@ -140,7 +142,7 @@ func TestSetEntry(t *testing.T) {
 						}
 
 						// Verify the written data with new storage
-						ssTmp, err := NewService(deploymentID, &conf, encryptor, testLogLevel)
+						ssTmp, err := NewService(deploymentID, &conf, encryptor, policy, testLogLevel)
 						assert.NoError(t, err)
 
 						result, err := getEntriesReload(ssTmp, appName, scope)
@@ -171,7 +173,7 @@ func TestDeleteEntry(t *testing.T) {
 	for testEncName, encryptor := range encryptors {
 		for testDbName, conf := range configs {
 			t.Run(testDbName+"-"+testEncName, func(t *testing.T) {
-				ss, err := NewService(deploymentID, &conf, encryptor, testLogLevel)
+				ss, err := NewService(deploymentID, &conf, encryptor, policy, testLogLevel)
 				assert.NoError(t, err)
 
 				for _, scope := range scopes {
@@ -199,7 +201,7 @@ func TestDeleteEntry(t *testing.T) {
 						err = ss.Write(appName, scope)
 						assert.NoError(t, err)
 
-						ssTmp, err := NewService(deploymentID, &conf, encryptor, testLogLevel)
+						ssTmp, err := NewService(deploymentID, &conf, encryptor, policy, testLogLevel)
 						assert.NoError(t, err)
 
 						entry, err = getEntryReload(ssTmp, appName, scope, key)
@@ -222,7 +224,7 @@ func TestListAppNames(t *testing.T) {
 	for testEncName, encryptor := range encryptors {
 		for testDbName, conf := range configs {
 			t.Run(testDbName+"-"+testEncName, func(t *testing.T) {
-				ss, err := NewService(deploymentID, &conf, encryptor, testLogLevel)
+				ss, err := NewService(deploymentID, &conf, encryptor, policy, testLogLevel)
 				assert.NoError(t, err)
 
 				for _, appName := range appNames {
@@ -240,7 +242,7 @@ func TestListAppNames(t *testing.T) {
 					}
 				}
 
-				ssTmp, err := NewService(deploymentID, &conf, encryptor, testLogLevel)
+				ssTmp, err := NewService(deploymentID, &conf, encryptor, policy, testLogLevel)
 				assert.NoError(t, err)
 
 				apps, err := ssTmp.ListAppNames()
@@ -259,7 +261,7 @@ func TestServiceSetGetEntriesIncreaseVersion(t *testing.T) {
 	for testEncName, encryptor := range encryptors {
 		for testDbName, conf := range configs {
 			t.Run(testDbName+"-"+testEncName, func(t *testing.T) {
-				ss, err := NewService(deploymentID, &conf, encryptor, testLogLevel)
+				ss, err := NewService(deploymentID, &conf, encryptor, policy, testLogLevel)
 				assert.NoError(t, err)
 
 				for _, scope := range scopes {
@@ -276,7 +278,7 @@ func TestServiceSetGetEntriesIncreaseVersion(t *testing.T) {
 						}
 
 						// Create a Service from scratch
-						ssTmp, err := NewService(deploymentID, &conf, encryptor, 1)
+						ssTmp, err := NewService(deploymentID, &conf, encryptor, policy, 1)
 						assert.NoError(t, err)
 
 						// Get and assert Entries in each scope after save
@@ -300,7 +302,7 @@ func TestServiceSetGetEntriesIncreaseVersion(t *testing.T) {
 						err = ssTmp.Write(appName, scope)
 						assert.NoError(t, err)
 
-						ssTmp2, err := NewService(deploymentID, &conf, encryptor, testLogLevel)
+						ssTmp2, err := NewService(deploymentID, &conf, encryptor, policy, testLogLevel)
 						assert.NoError(t, err)
 
 						data, err = getEntriesReload(ssTmp2, appName, scope)
@@ -318,3 +320,30 @@ func TestServiceSetGetEntriesIncreaseVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestLargeIntegers(t *testing.T) {
+	for testEncName, encryptor := range encryptors {
+		for testDbName, conf := range configs {
+			t.Run(testDbName+"-"+testEncName, func(t *testing.T) {
+				ss, err := NewService(deploymentID, &conf, encryptor, policy, testLogLevel)
+				assert.NoError(t, err)
+
+				for _, scope := range scopes {
+					assert.NoError(t, ss.Read("finex", scope))
+					assert.NoError(t, ss.SetEntry("finex", scope, "finex_max_id", json.Number("9007199254740993")))
+					assert.NoError(t, ss.Write("finex", scope))
+
+					ssTmp, err := NewService(deploymentID, &conf, encryptor, policy, testLogLevel)
+					assert.NoError(t, err)
+
+					entry, err := getEntryReload(ssTmp, "finex", scope, "finex_max_id")
+					assert.NoError(t, err)
+					assert.Equal(t, json.Number("9007199254740993"), entry)
+				}
+
+				err = clearStorage(conf)
+				assert.NoError(t, err)
+			})
+		}
+	}
+}
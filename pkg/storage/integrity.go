@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/openware/kaigara/pkg/logger"
+	"github.com/openware/kaigara/types"
+)
+
+// IntegrityKey is the reserved entry holding the integrity record of a scope
+const IntegrityKey = "kaigara_integrity"
+
+// IntegrityKeySize is the required length of the HMAC key in bytes
+const IntegrityKeySize = 32
+
+const integrityAlg = "hmac-sha256"
+
+// ErrIntegrityMissing is returned when a scope holding values, or already signed, has no integrity record
+var ErrIntegrityMissing = errors.New("integrity record is missing")
+
+// ErrIntegrityMismatch is returned when the integrity record doesn't match the values of a scope
+var ErrIntegrityMismatch = errors.New("integrity record doesn't match the stored values")
+
+// VersionedStorage is implemented by the drivers able to tell the version their next write gives to a document
+type VersionedStorage interface {
+	types.Storage
+	GetNextVersion(appName, scope string) (int64, error)
+}
+
+// IntegrityStorage wraps a storage driver to sign every app/scope document with an HMAC on Write and verify it on Read.
+// The record is stored as the IntegrityKey entry of the document, it holds a sequence number incremented on each write.
+// The HMAC covers the values, the sequence and the version the driver gives to the document, so a document replaced
+// by an older version of itself fails if the driver bumped its version, like Vault does, and a sequence lower
+// than the last one verified is refused.
+type IntegrityStorage struct {
+	types.Storage
+	versions VersionedStorage
+
+	key     []byte
+	enforce bool
+	// shared is set for drivers storing all scopes of an app in a single document, like K8s
+	shared bool
+
+	sequences map[string]int64
+}
+
+// NewIntegrityStorage instantiates an integrity layer around the storage.
+// With enforce, Read fails on a missing or mismatching record, otherwise only a warning is logged.
+func NewIntegrityStorage(ss VersionedStorage, key []byte, enforce, shared bool) *IntegrityStorage {
+	return &IntegrityStorage{
+		Storage:   ss,
+		versions:  ss,
+		key:       key,
+		enforce:   enforce,
+		shared:    shared,
+		sequences: make(map[string]int64),
+	}
+}
+
+// Read loads the document and verifies its integrity record
+func (is *IntegrityStorage) Read(appName, scope string) error {
+	if err := is.Storage.Read(appName, scope); err != nil {
+		return err
+	}
+
+	if err := is.Verify(appName, scope); err != nil {
+		if is.enforce {
+			return fmt.Errorf("integrity check of %s.%s failed: %s", appName, scope, err)
+		}
+		logger.Warn("integrity check failed", logger.F("app", appName), logger.F("scope", scope), logger.Err(err))
+	}
+
+	return nil
+}
+
+// Write signs the document and saves it
+func (is *IntegrityStorage) Write(appName, scope string) error {
+	entries, err := is.entries(appName, scope)
+	if err != nil {
+		return err
+	}
+
+	version, err := is.versions.GetNextVersion(appName, scope)
+	if err != nil {
+		return err
+	}
+
+	sequence := is.sequences[is.documentID(appName, scope)] + 1
+	mac, err := is.sign(appName, scope, version, sequence, entries)
+	if err != nil {
+		return err
+	}
+
+	record := fmt.Sprintf("%s:%d:%s", integrityAlg, sequence, mac)
+	if err := is.Storage.SetEntry(appName, scope, IntegrityKey, record); err != nil {
+		return err
+	}
+
+	if err := is.Storage.Write(appName, scope); err != nil {
+		return err
+	}
+	is.sequences[is.documentID(appName, scope)] = sequence
+
+	return nil
+}
+
+// Verify checks the integrity record of a document already loaded by the wrapped storage
+func (is *IntegrityStorage) Verify(appName, scope string) error {
+	raw, err := is.Storage.GetEntries(appName, scope)
+	if err != nil {
+		return err
+	}
+
+	entries := filterEntries(raw)
+	record, ok := raw[IntegrityKey].(string)
+	if !ok {
+		// An empty document doesn't need a record, unless it was signed before, like a document deleted
+		// along with its record
+		if _, signed := is.sequences[is.documentID(appName, scope)]; !signed && len(entries) == 0 {
+			return nil
+		}
+		return ErrIntegrityMissing
+	}
+
+	parts := strings.SplitN(record, ":", 3)
+	if len(parts) != 3 || parts[0] != integrityAlg {
+		return fmt.Errorf("%w: invalid record", ErrIntegrityMismatch)
+	}
+
+	sequence, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid sequence", ErrIntegrityMismatch)
+	}
+
+	if last := is.sequences[is.documentID(appName, scope)]; sequence < last {
+		return fmt.Errorf("%w: sequence %d is lower than the last verified one %d", ErrIntegrityMismatch, sequence, last)
+	}
+
+	version, err := is.Storage.GetCurrentVersion(appName, scope)
+	if err != nil {
+		return err
+	}
+
+	expected, err := is.sign(appName, scope, version, sequence, entries)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return ErrIntegrityMismatch
+	}
+	is.sequences[is.documentID(appName, scope)] = sequence
+
+	return nil
+}
+
+// SetEntry refuses to overwrite the integrity record
+func (is *IntegrityStorage) SetEntry(appName, scope, name string, value interface{}) error {
+	if name == IntegrityKey {
+		return fmt.Errorf("%s is a reserved entry name", IntegrityKey)
+	}
+
+	return is.Storage.SetEntry(appName, scope, name, value)
+}
+
+// SetEntries refuses to overwrite the integrity record
+func (is *IntegrityStorage) SetEntries(appName, scope string, data map[string]interface{}) error {
+	if _, ok := data[IntegrityKey]; ok {
+		return fmt.Errorf("%s is a reserved entry name", IntegrityKey)
+	}
+
+	return is.Storage.SetEntries(appName, scope, data)
+}
+
+// GetEntry hides the integrity record
+func (is *IntegrityStorage) GetEntry(appName, scope, name string) (interface{}, error) {
+	if name == IntegrityKey {
+		return nil, nil
+	}
+
+	return is.Storage.GetEntry(appName, scope, name)
+}
+
+// GetEntries returns the entries of the scope without the integrity record
+func (is *IntegrityStorage) GetEntries(appName, scope string) (map[string]interface{}, error) {
+	entries, err := is.Storage.GetEntries(appName, scope)
+	if err != nil {
+		return nil, err
+	}
+	delete(entries, IntegrityKey)
+
+	return entries, nil
+}
+
+// ListEntries returns the entry names of the scope without the integrity record
+func (is *IntegrityStorage) ListEntries(appName, scope string) ([]string, error) {
+	names, err := is.Storage.ListEntries(appName, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(names))
+	for _, name := range names {
+		if name != IntegrityKey {
+			res = append(res, name)
+		}
+	}
+
+	return res, nil
+}
+
+// DeleteEntry refuses to delete the integrity record
+func (is *IntegrityStorage) DeleteEntry(appName, scope, name string) error {
+	if name == IntegrityKey {
+		return fmt.Errorf("%s is a reserved entry name", IntegrityKey)
+	}
+
+	return is.Storage.DeleteEntry(appName, scope, name)
+}
+
+func (is *IntegrityStorage) documentID(appName, scope string) string {
+	if is.shared {
+		return appName
+	}
+
+	return appName + "." + scope
+}
+
+func (is *IntegrityStorage) entries(appName, scope string) (map[string]interface{}, error) {
+	raw, err := is.Storage.GetEntries(appName, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterEntries(raw), nil
+}
+
+// sign computes the HMAC of the document at the driver version, values are canonicalized so that numbers
+// parsed back as floats or values stringified by the driver produce the same MAC
+func (is *IntegrityStorage) sign(appName, scope string, version, sequence int64, entries map[string]interface{}) (string, error) {
+	canonical := make(map[string]string, len(entries))
+	for name, value := range entries {
+		if str, ok := value.(string); ok {
+			canonical[name] = str
+			continue
+		}
+
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid value for %s: %s", name, err)
+		}
+		canonical[name] = string(raw)
+	}
+
+	// encoding/json sorts map keys, which makes the document canonical
+	document, err := json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+
+	if is.shared {
+		scope = ""
+	}
+
+	mac := hmac.New(sha256.New, is.key)
+	fmt.Fprintf(mac, "%s\x00%s\x00%d\x00%d\x00", appName, scope, version, sequence)
+	mac.Write(document)
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// filterEntries returns the entries covered by the integrity record, the version is bound by the driver's own one
+func filterEntries(raw map[string]interface{}) map[string]interface{} {
+	entries := make(map[string]interface{}, len(raw))
+	for name, value := range raw {
+		if name != IntegrityKey && name != "version" {
+			entries[name] = value
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/k8s"
+	"github.com/openware/pkg/kube"
+)
+
+var integrityKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newIntegrityStorage(t *testing.T, client *kube.K8sClient, enforce bool) *IntegrityStorage {
+	return NewIntegrityStorage(k8s.NewMockService(client), integrityKey, enforce, true)
+}
+
+// integrityRecord returns the record stored with the secret entries of finex
+func integrityRecord(t *testing.T, client *kube.K8sClient) string {
+	ss := k8s.NewMockService(client)
+	assert.NoError(t, ss.Read("finex", "secret"))
+
+	record, err := ss.GetEntry("finex", "secret", IntegrityKey)
+	assert.NoError(t, err)
+
+	return fmt.Sprint(record)
+}
+
+func TestIntegrityStorage(t *testing.T) {
+	client := k8s.NewMockClient()
+	is := newIntegrityStorage(t, client, true)
+
+	assert.NoError(t, is.Read("finex", "secret"))
+	assert.NoError(t, is.SetEntry("finex", "secret", "finex_database_password", "changeme"))
+	assert.NoError(t, is.SetEntry("finex", "secret", "finex_workers", 4))
+	assert.NoError(t, is.Write("finex", "secret"))
+
+	assert.Contains(t, integrityRecord(t, client), "hmac-sha256:1:")
+
+	// Numbers are read back as strings or floats depending on the driver
+	is = newIntegrityStorage(t, client, true)
+	assert.NoError(t, is.Read("finex", "secret"))
+
+	entries, err := is.GetEntries("finex", "secret")
+	assert.NoError(t, err)
+	assert.NotContains(t, entries, IntegrityKey)
+
+	names, err := is.ListEntries("finex", "secret")
+	assert.NoError(t, err)
+	assert.NotContains(t, names, IntegrityKey)
+
+	assert.Error(t, is.SetEntry("finex", "secret", IntegrityKey, "forged"))
+	assert.Error(t, is.DeleteEntry("finex", "secret", IntegrityKey))
+
+	assert.NoError(t, is.SetEntry("finex", "secret", "finex_log_level", "debug"))
+	assert.NoError(t, is.Write("finex", "secret"))
+
+	assert.Contains(t, integrityRecord(t, client), "hmac-sha256:2:")
+}
+
+func TestIntegrityStorageTampered(t *testing.T) {
+	client := k8s.NewMockClient()
+	is := newIntegrityStorage(t, client, true)
+
+	assert.NoError(t, is.Read("finex", "secret"))
+	assert.NoError(t, is.SetEntry("finex", "secret", "finex_database_password", "changeme"))
+	assert.NoError(t, is.Write("finex", "secret"))
+
+	writer := k8s.NewMockService(client)
+	assert.NoError(t, writer.Read("finex", "secret"))
+	assert.NoError(t, writer.SetEntry("finex", "secret", "finex_database_password", "hacked"))
+	assert.NoError(t, writer.Write("finex", "secret"))
+
+	is = newIntegrityStorage(t, client, true)
+	err := is.Read("finex", "secret")
+	assert.ErrorContains(t, err, "integrity check of finex.secret failed")
+
+	assert.NoError(t, is.Storage.Read("finex", "secret"))
+	assert.True(t, errors.Is(is.Verify("finex", "secret"), ErrIntegrityMismatch))
+
+	// Warn mode only logs the mismatch
+	is = newIntegrityStorage(t, client, false)
+	assert.NoError(t, is.Read("finex", "secret"))
+}
+
+func TestIntegrityStorageMissing(t *testing.T) {
+	client := k8s.NewMockClient()
+	ss := k8s.NewMockService(client)
+	assert.NoError(t, ss.Read("finex", "secret"))
+	assert.NoError(t, ss.SetEntry("finex", "secret", "finex_database_password", "changeme"))
+	assert.NoError(t, ss.Write("finex", "secret"))
+
+	is := newIntegrityStorage(t, client, true)
+	assert.ErrorContains(t, is.Read("finex", "secret"), ErrIntegrityMissing.Error())
+
+	// Empty documents don't need a record
+	assert.NoError(t, is.Read("peatio", "secret"))
+
+	// Unless they were signed before, like a document deleted along with its record
+	assert.NoError(t, is.SetEntry("peatio", "secret", "peatio_database_password", "changeme"))
+	assert.NoError(t, is.Write("peatio", "secret"))
+	assert.NoError(t, client.DeleteSecret("kaigara-peatio", "odax"))
+	assert.ErrorContains(t, is.Read("peatio", "secret"), ErrIntegrityMissing.Error())
+}
+
+func TestIntegrityStorageRollback(t *testing.T) {
+	client := k8s.NewMockClient()
+	is := newIntegrityStorage(t, client, true)
+
+	assert.NoError(t, is.Read("finex", "secret"))
+	assert.NoError(t, is.SetEntry("finex", "secret", "finex_database_password", "changeme"))
+	assert.NoError(t, is.Write("finex", "secret"))
+
+	old, err := client.ReadSecret("kaigara-finex", "odax")
+	assert.NoError(t, err)
+	rollback := map[string]interface{}{}
+	for name, value := range old {
+		rollback[name] = string(value)
+	}
+
+	assert.NoError(t, is.Read("finex", "secret"))
+	assert.NoError(t, is.SetEntry("finex", "secret", "finex_database_password", "rotated"))
+	assert.NoError(t, is.Write("finex", "secret"))
+	assert.NoError(t, is.Read("finex", "secret"))
+
+	// The previous document with its record is restored, it's signed with a lower sequence
+	assert.NoError(t, client.UpdateSecret("kaigara-finex", "odax", rollback))
+	assert.ErrorContains(t, is.Read("finex", "secret"), "sequence 1 is lower than the last verified one 2")
+
+	// The record is bound to the version of the document
+	rollback["version"] = "5"
+	assert.NoError(t, client.UpdateSecret("kaigara-finex", "odax", rollback))
+	is = newIntegrityStorage(t, client, true)
+	assert.NoError(t, is.Storage.Read("finex", "secret"))
+	assert.True(t, errors.Is(is.Verify("finex", "secret"), ErrIntegrityMismatch))
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openware/kaigara/pkg/config"
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
+	"github.com/openware/kaigara/pkg/encryptor/keys"
+	"github.com/openware/kaigara/pkg/encryptor/xchacha"
+	"github.com/openware/kaigara/pkg/logger"
+)
+
+// DefaultAESKey is the well-known AES key used when none is configured, it's refused unless insecure mode is on
+const DefaultAESKey = "changemechangeme"
+
+// aesKeySizes are the key lengths of AES-128, AES-192 and AES-256
+var aesKeySizes = []int{16, 24, 32}
+
+// loadAESKey returns the AES key from the key file, the inline key or the passphrase, in this order.
+// The inline key is used as raw bytes unless it has a "hex:" or "base64:" prefix.
+func loadAESKey(conf *config.KaigaraConfig) ([]byte, error) {
+	if conf.AesKeyFile != "" {
+		return keys.LoadFile(conf.AesKeyFile, aesKeySizes...)
+	}
+
+	if conf.AesKey != "" && conf.AesKey != DefaultAESKey {
+		if strings.HasPrefix(conf.AesKey, "hex:") || strings.HasPrefix(conf.AesKey, "base64:") {
+			return keys.Decode(conf.AesKey, aesKeySizes...)
+		}
+
+		return []byte(conf.AesKey), nil
+	}
+
+	if hasPassphrase(conf) {
+		return deriveKey(conf, envelope.AlgAES, 32)
+	}
+
+	if conf.AesKey == DefaultAESKey {
+		if !conf.Insecure {
+			return nil, fmt.Errorf("AES key is the well-known default, set KAIGARA_ENCRYPTOR_AES_KEY, KAIGARA_ENCRYPTOR_AES_KEY_FILE or KAIGARA_ENCRYPTOR_PASSPHRASE, or KAIGARA_ENCRYPTOR_INSECURE=true to use it anyway")
+		}
+
+		logger.Warn("using the well-known default AES key, secrets are not protected")
+		return []byte(DefaultAESKey), nil
+	}
+
+	return nil, fmt.Errorf("AES key is not set, use KAIGARA_ENCRYPTOR_AES_KEY, KAIGARA_ENCRYPTOR_AES_KEY_FILE or KAIGARA_ENCRYPTOR_PASSPHRASE")
+}
+
+// loadXChaChaKey returns the XChaCha20-Poly1305 key from the key file, the inline key or the passphrase, in this order
+func loadXChaChaKey(conf *config.KaigaraConfig) ([]byte, error) {
+	if conf.XChaChaKeyFile != "" {
+		return keys.LoadFile(conf.XChaChaKeyFile, xchacha.KeySize)
+	}
+
+	if conf.XChaChaKey != "" {
+		return keys.Decode(conf.XChaChaKey, xchacha.KeySize)
+	}
+
+	if hasPassphrase(conf) {
+		return deriveKey(conf, envelope.AlgXChaCha, xchacha.KeySize)
+	}
+
+	return nil, fmt.Errorf("XChaCha20-Poly1305 key is not set, use KAIGARA_ENCRYPTOR_XCHACHA_KEY, KAIGARA_ENCRYPTOR_XCHACHA_KEY_FILE or KAIGARA_ENCRYPTOR_PASSPHRASE")
+}
+
+func hasPassphrase(conf *config.KaigaraConfig) bool {
+	return conf.Passphrase != "" || conf.PassphraseFile != ""
+}
+
+// deriveKey derives the key of the alg encryptor from the configured passphrase and salt
+func deriveKey(conf *config.KaigaraConfig, alg string, size int) ([]byte, error) {
+	passphrase := conf.Passphrase
+	if conf.PassphraseFile != "" {
+		data, err := os.ReadFile(conf.PassphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase file: %s", err)
+		}
+		passphrase = strings.TrimRight(string(data), "\r\n")
+	}
+
+	if conf.Salt == "" {
+		return nil, fmt.Errorf("a salt is required to derive keys from the passphrase, set KAIGARA_ENCRYPTOR_SALT")
+	}
+
+	salt, err := keys.DecodeSalt(conf.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys.Derive(passphrase, salt, alg, size)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/config"
+)
+
+const testSalt = "000102030405060708090a0b0c0d0e0f"
+
+func TestLoadAESKey(t *testing.T) {
+	key, err := loadAESKey(&config.KaigaraConfig{AesKey: "1234567890123456"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1234567890123456"), key)
+
+	key, err = loadAESKey(&config.KaigaraConfig{AesKey: "hex:000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"})
+	assert.NoError(t, err)
+	assert.Len(t, key, 32)
+
+	keyPath := path.Join(t.TempDir(), "aes.key")
+	assert.NoError(t, os.WriteFile(keyPath, []byte("AAECAwQFBgcICQoLDA0ODw==\n"), 0600))
+	key, err = loadAESKey(&config.KaigaraConfig{AesKey: DefaultAESKey, AesKeyFile: keyPath})
+	assert.NoError(t, err)
+	assert.Len(t, key, 16)
+
+	_, err = loadAESKey(&config.KaigaraConfig{})
+	assert.Error(t, err)
+}
+
+func TestLoadAESKeyDefault(t *testing.T) {
+	_, err := loadAESKey(&config.KaigaraConfig{AesKey: DefaultAESKey})
+	assert.ErrorContains(t, err, "well-known default")
+
+	key, err := loadAESKey(&config.KaigaraConfig{AesKey: DefaultAESKey, Insecure: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(DefaultAESKey), key)
+}
+
+func TestLoadKeyFromPassphrase(t *testing.T) {
+	conf := &config.KaigaraConfig{AesKey: DefaultAESKey, Passphrase: "correct horse battery staple", Salt: testSalt}
+
+	aesKey, err := loadAESKey(conf)
+	assert.NoError(t, err)
+	assert.Len(t, aesKey, 32)
+
+	xchachaKey, err := loadXChaChaKey(conf)
+	assert.NoError(t, err)
+	assert.Len(t, xchachaKey, 32)
+	assert.NotEqual(t, aesKey, xchachaKey)
+
+	passphrasePath := path.Join(t.TempDir(), "passphrase")
+	assert.NoError(t, os.WriteFile(passphrasePath, []byte("correct horse battery staple\n"), 0600))
+	key, err := loadAESKey(&config.KaigaraConfig{PassphraseFile: passphrasePath, Salt: testSalt})
+	assert.NoError(t, err)
+	assert.Equal(t, aesKey, key)
+
+	_, err = loadXChaChaKey(&config.KaigaraConfig{Passphrase: "correct horse battery staple"})
+	assert.ErrorContains(t, err, "salt is required")
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/openware/kaigara/types"
+)
+
+// LazyStorage connects to the storage driver on its first call, and again on the next calls until it succeeds,
+// so kaigara can start while the storage is unreachable
+type LazyStorage struct {
+	connect func() (types.Storage, error)
+
+	mu sync.Mutex
+	ss types.Storage
+}
+
+// NewLazyStorage instantiates a storage connected with connect when it's first used
+func NewLazyStorage(connect func() (types.Storage, error)) *LazyStorage {
+	return &LazyStorage{connect: connect}
+}
+
+// storage returns the connected storage driver
+func (ls *LazyStorage) storage() (types.Storage, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.ss != nil {
+		return ls.ss, nil
+	}
+
+	ss, err := ls.connect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the secret storage: %s", err)
+	}
+	ls.ss = ss
+
+	return ss, nil
+}
+
+func (ls *LazyStorage) Read(appName, scope string) error {
+	ss, err := ls.storage()
+	if err != nil {
+		return err
+	}
+
+	return ss.Read(appName, scope)
+}
+
+func (ls *LazyStorage) Write(appName, scope string) error {
+	ss, err := ls.storage()
+	if err != nil {
+		return err
+	}
+
+	return ss.Write(appName, scope)
+}
+
+func (ls *LazyStorage) SetEntry(appName, scope, name string, value interface{}) error {
+	ss, err := ls.storage()
+	if err != nil {
+		return err
+	}
+
+	return ss.SetEntry(appName, scope, name, value)
+}
+
+func (ls *LazyStorage) SetEntries(appName, scope string, data map[string]interface{}) error {
+	ss, err := ls.storage()
+	if err != nil {
+		return err
+	}
+
+	return ss.SetEntries(appName, scope, data)
+}
+
+func (ls *LazyStorage) GetEntry(appName, scope, name string) (interface{}, error) {
+	ss, err := ls.storage()
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.GetEntry(appName, scope, name)
+}
+
+func (ls *LazyStorage) GetEntries(appName, scope string) (map[string]interface{}, error) {
+	ss, err := ls.storage()
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.GetEntries(appName, scope)
+}
+
+func (ls *LazyStorage) ListEntries(appName, scope string) ([]string, error) {
+	ss, err := ls.storage()
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.ListEntries(appName, scope)
+}
+
+func (ls *LazyStorage) DeleteEntry(appName, scope, name string) error {
+	ss, err := ls.storage()
+	if err != nil {
+		return err
+	}
+
+	return ss.DeleteEntry(appName, scope, name)
+}
+
+func (ls *LazyStorage) ListAppNames() ([]string, error) {
+	ss, err := ls.storage()
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.ListAppNames()
+}
+
+func (ls *LazyStorage) GetCurrentVersion(appName, scope string) (int64, error) {
+	ss, err := ls.storage()
+	if err != nil {
+		return 0, err
+	}
+
+	return ss.GetCurrentVersion(appName, scope)
+}
+
+func (ls *LazyStorage) GetLatestVersion(appName, scope string) (int64, error) {
+	ss, err := ls.storage()
+	if err != nil {
+		return 0, err
+	}
+
+	return ss.GetLatestVersion(appName, scope)
+}
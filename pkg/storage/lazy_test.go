@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/k8s"
+	"github.com/openware/kaigara/types"
+)
+
+func TestLazyStorage(t *testing.T) {
+	attempts := 0
+	ls := NewLazyStorage(func() (types.Storage, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("connection refused")
+		}
+		return k8s.NewMockService(k8s.NewMockClient()), nil
+	})
+
+	err := ls.Read("finex", "public")
+	assert.ErrorContains(t, err, "failed to connect to the secret storage: connection refused")
+	_, err = ls.GetLatestVersion("finex", "public")
+	assert.Error(t, err)
+
+	assert.NoError(t, ls.Read("finex", "public"))
+	assert.NoError(t, ls.SetEntry("finex", "public", "finex_host", "localhost"))
+	assert.NoError(t, ls.Write("finex", "public"))
+
+	value, err := ls.GetEntry("finex", "public", "finex_host")
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost", value)
+
+	// The storage is connected only once
+	assert.Equal(t, 3, attempts)
+}
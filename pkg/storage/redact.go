@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	enc "github.com/openware/kaigara/pkg/encryptor/types"
+	"github.com/openware/kaigara/pkg/logger"
+	"github.com/openware/kaigara/types"
+)
+
+// redactingStorage registers with the logger the values it reads or writes in the encrypted scopes, and the ones
+// of the keys named like secrets in the other scopes, so they're never logged by kaigara or kai
+type redactingStorage struct {
+	types.Storage
+	secrets enc.ScopePolicy
+}
+
+func (rs *redactingStorage) SetEntry(appName, scope, name string, value interface{}) error {
+	rs.redact(scope, name, value)
+
+	return rs.Storage.SetEntry(appName, scope, name, value)
+}
+
+func (rs *redactingStorage) SetEntries(appName, scope string, data map[string]interface{}) error {
+	for name, value := range data {
+		rs.redact(scope, name, value)
+	}
+
+	return rs.Storage.SetEntries(appName, scope, data)
+}
+
+func (rs *redactingStorage) GetEntry(appName, scope, name string) (interface{}, error) {
+	value, err := rs.Storage.GetEntry(appName, scope, name)
+	if err != nil {
+		return nil, err
+	}
+	rs.redact(scope, name, value)
+
+	return value, nil
+}
+
+func (rs *redactingStorage) GetEntries(appName, scope string) (map[string]interface{}, error) {
+	entries, err := rs.Storage.GetEntries(appName, scope)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range entries {
+		rs.redact(scope, name, value)
+	}
+
+	return entries, nil
+}
+
+// GetNextVersion returns the version the next write gives to the document, if the wrapped driver tells it
+func (rs *redactingStorage) GetNextVersion(appName, scope string) (int64, error) {
+	versioned, ok := rs.Storage.(VersionedStorage)
+	if !ok {
+		return 0, fmt.Errorf("storage doesn't tell the version of its next write")
+	}
+
+	return versioned.GetNextVersion(appName, scope)
+}
+
+// redact registers the value if it's stored in an encrypted scope or under a key named like a secret
+func (rs *redactingStorage) redact(scope, name string, value interface{}) {
+	if name == "version" || name == IntegrityKey {
+		return
+	}
+
+	if rs.secrets.IsEncrypted(scope) || logger.IsSecretKey(name) {
+		redactValue(value)
+	}
+}
+
+// redactValue registers the value as given to the command: strings and numbers as is,
+// maps and arrays as base64 encoded JSON, along with the values they hold
+func redactValue(value interface{}) {
+	switch v := value.(type) {
+	case string:
+		logger.Redact(v)
+	case json.Number:
+		logger.Redact(v.String())
+	case float64:
+		logger.Redact(strconv.FormatFloat(v, 'f', -1, 64))
+	case []interface{}:
+		redactComposite(v)
+		for _, item := range v {
+			redactValue(item)
+		}
+	case map[string]interface{}:
+		redactComposite(v)
+		for _, item := range v {
+			redactValue(item)
+		}
+	}
+}
+
+func redactComposite(value interface{}) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	logger.Redact(string(raw), base64.StdEncoding.EncodeToString(raw))
+}
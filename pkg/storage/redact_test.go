@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	enc "github.com/openware/kaigara/pkg/encryptor/types"
+	"github.com/openware/kaigara/pkg/k8s"
+	"github.com/openware/kaigara/pkg/logger"
+)
+
+func TestRedactingStorage(t *testing.T) {
+	// The values are written by another process, they're only known once read
+	client := k8s.NewMockClient()
+	ss := k8s.NewMockService(client)
+	assert.NoError(t, ss.Read("barong", "public"))
+	assert.NoError(t, ss.Read("finex", "secret"))
+	assert.NoError(t, ss.SetEntries("barong", "public", map[string]interface{}{
+		"barong_log_level":         "information",
+		"barong_database_password": "s3cr3t-barong-pass",
+	}))
+	assert.NoError(t, ss.SetEntries("finex", "secret", map[string]interface{}{
+		"finex_database_pass": "s3cr3t-db-pass",
+		"finex_port":          json.Number("987654"),
+		"finex_config":        map[string]interface{}{"api_key": "s3cr3t-api-key"},
+	}))
+	assert.NoError(t, ss.Write("barong", "public"))
+	assert.NoError(t, ss.Write("finex", "secret"))
+
+	rs := &redactingStorage{Storage: k8s.NewMockService(client), secrets: enc.NewScopePolicy(nil)}
+	for app, scope := range map[string]string{"barong": "public", "finex": "secret"} {
+		assert.NoError(t, rs.Read(app, scope))
+		_, err := rs.GetEntries(app, scope)
+		assert.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+	defer logger.SetDefault(logger.Default())
+	logger.SetDefault(logger.New(&buf, "Kaigara", logger.InfoLevel, logger.FormatJSON))
+
+	composite := base64.StdEncoding.EncodeToString([]byte(`{"api_key":"s3cr3t-api-key"}`))
+	logger.Info("connecting with s3cr3t-db-pass", logger.F("dsn", "postgres://finex:s3cr3t-db-pass@db:987654"),
+		logger.F("vars", []string{"FINEX_CONFIG=" + composite, "FINEX_API_KEY=s3cr3t-api-key"}),
+		logger.F("barong", "level information, password s3cr3t-barong-pass"))
+	for _, secret := range []string{"s3cr3t-db-pass", "987654", "s3cr3t-api-key", composite, "s3cr3t-barong-pass"} {
+		assert.NotContains(t, buf.String(), secret)
+	}
+	assert.Contains(t, buf.String(), `"dsn":"postgres://finex:[REDACTED]@db:[REDACTED]"`)
+	assert.Contains(t, buf.String(), "level information")
+}
@@ -2,37 +2,60 @@ package storage
 
 import (
 	"fmt"
-	"log"
+	"io"
 	"strings"
 
 	"github.com/openware/kaigara/pkg/config"
 	"github.com/openware/kaigara/pkg/encryptor/aes"
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
+	"github.com/openware/kaigara/pkg/encryptor/exec"
+	"github.com/openware/kaigara/pkg/encryptor/keys"
 	"github.com/openware/kaigara/pkg/encryptor/plaintext"
 	"github.com/openware/kaigara/pkg/encryptor/transit"
 	enc "github.com/openware/kaigara/pkg/encryptor/types"
+	"github.com/openware/kaigara/pkg/encryptor/xchacha"
 	"github.com/openware/kaigara/pkg/k8s"
+	"github.com/openware/kaigara/pkg/logger"
 	"github.com/openware/kaigara/pkg/sql"
 	"github.com/openware/kaigara/pkg/vault"
 	"github.com/openware/kaigara/types"
 	"github.com/openware/pkg/kube"
+	gormlogger "gorm.io/gorm/logger"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-func GetStorageService(conf *config.KaigaraConfig) (types.Storage, error) {
-	var storage types.Storage
-	var err error
+func init() {
+	// The encryptors and the storage drivers log with the logger of kai and kaigara
+	enc.SetLogger(logger.KeyValue{})
+}
 
+func GetStorageService(conf *config.KaigaraConfig) (types.Storage, error) {
 	enc, err := NewEncryptor(conf)
 	if err != nil {
 		return nil, err
 	}
 
+	return NewStorageService(conf, enc)
+}
+
+// NewStorageService instantiates the configured storage driver with the given encryptor
+func NewStorageService(conf *config.KaigaraConfig, encryptor enc.Encryptor) (types.Storage, error) {
+	var storage types.Storage
+	var err error
+
+	policy := enc.NewScopePolicy(conf.EncryptedScopes)
+	secrets := policy
+
 	switch conf.Storage {
 	case "vault":
-		storage, err = vault.NewService(conf.DeploymentID, enc, conf.VaultAddr, conf.VaultToken)
+		storage, err = vault.NewService(conf.DeploymentID, encryptor, policy, conf.VaultAddr, conf.VaultToken)
 	case "sql":
-		storage, err = sql.NewService(conf.DeploymentID, &conf.DBConfig, enc, conf.LogLevel)
+		storage, err = sql.NewService(conf.DeploymentID, &conf.DBConfig, encryptor, policy, sqlLogLevel(conf.LogLevel))
 	case "k8s":
+		warnK8sPolicy(policy)
+		// All the scopes of an app are read at once, only the keys named like secrets can be told apart
+		secrets = enc.ScopePolicy{}
+
 		// create a new client from kubeconfig
 		config, cfgErr := clientcmd.BuildConfigFromFlags("", conf.KubeConfig)
 		if cfgErr != nil {
@@ -44,44 +67,149 @@ func GetStorageService(conf *config.KaigaraConfig) (types.Storage, error) {
 			return nil, cfgErr
 		}
 
-		storage, err = k8s.NewService(conf.DeploymentID, client, enc)
+		storage, err = k8s.NewService(conf.DeploymentID, client, encryptor)
 	default:
 		return nil, fmt.Errorf("type %s is not supported", conf.Storage)
 	}
 
-	if err == nil {
-		log.Printf("INF: using %s secret storage", conf.Storage)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("using secret storage", logger.F("storage", conf.Storage))
+
+	return withIntegrity(conf, &redactingStorage{Storage: storage, secrets: secrets})
+}
+
+// sqlLogLevel returns the gorm log level of the SQL driver for the log level, queries are only traced at debug level
+func sqlLogLevel(name string) int {
+	level, _ := logger.ParseLevel(name)
+
+	switch level {
+	case logger.DebugLevel:
+		return int(gormlogger.Info)
+	case logger.InfoLevel, logger.WarnLevel:
+		return int(gormlogger.Warn)
+	default:
+		return int(gormlogger.Error)
 	}
+}
 
-	return storage, err
+// warnK8sPolicy warns when encrypted scopes are configured for the k8s driver: it stores all the scopes of an app
+// in one secret, so it encrypts them all whatever the policy
+func warnK8sPolicy(policy enc.ScopePolicy) {
+	if strings.Join(policy.Scopes(), ",") != strings.Join(enc.DefaultEncryptedScopes, ",") {
+		logger.Warn("the k8s storage encrypts every scope, the encrypted scopes are ignored", logger.F("encrypted_scopes", policy.Scopes()))
+	}
 }
 
-func NewEncryptor(conf *config.KaigaraConfig) (enc.Encryptor, error) {
-	var enc enc.Encryptor
+// withIntegrity wraps the storage into an integrity layer if an integrity key is configured
+func withIntegrity(conf *config.KaigaraConfig, storage types.Storage) (types.Storage, error) {
+	if conf.IntegrityKey == "" && conf.IntegrityKeyFile == "" {
+		return storage, nil
+	}
+
+	var enforce bool
+	switch conf.IntegrityMode {
+	case "warn", "":
+	case "enforce":
+		enforce = true
+	default:
+		return nil, fmt.Errorf("integrity mode '%s' is not supported, use warn or enforce", conf.IntegrityMode)
+	}
+
+	var key []byte
 	var err error
+	if conf.IntegrityKeyFile != "" {
+		key, err = keys.LoadFile(conf.IntegrityKeyFile, IntegrityKeySize)
+	} else {
+		key, err = keys.Decode(conf.IntegrityKey, IntegrityKeySize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load integrity key: %s", err)
+	}
 
-	switch conf.EncryptMethod {
-	case "transit":
-		enc, err = transit.NewVaultEncryptor(conf.VaultAddr, conf.VaultToken)
-		if err == nil {
-			log.Println("INF: starting vault transit secret engine encryption!")
+	logger.Info("verifying secrets integrity", logger.F("mode", conf.IntegrityMode))
+
+	versioned, ok := storage.(VersionedStorage)
+	if !ok {
+		return nil, fmt.Errorf("storage %s doesn't support integrity", conf.Storage)
+	}
+
+	// K8s driver stores all scopes of an app in a single secret
+	return NewIntegrityStorage(versioned, key, enforce, conf.Storage == "k8s"), nil
+}
+
+// NewEncryptor returns an encryptor encrypting and decrypting with the configured method.
+// Values saved by the fallback methods are decrypted too, they're only given by the commands migrating secrets
+// since anyone able to write to the storage could otherwise downgrade the encryption of a value.
+func NewEncryptor(conf *config.KaigaraConfig, fallbacks ...string) (enc.Encryptor, error) {
+	primary, err := newEncryptor(conf, conf.EncryptMethod)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("starting encryption", logger.F("encryptor", conf.EncryptMethod))
+
+	dispatcher := envelope.NewDispatcher(conf.EncryptMethod, primary)
+
+	for _, method := range fallbacks {
+		if method == conf.EncryptMethod {
+			continue
 		}
 
-	case "aes":
-		enc, err = aes.NewAESEncryptor([]byte(conf.AesKey))
-		if err == nil {
-			log.Println("INF: starting in-memory encryption!")
+		encryptor, err := newEncryptor(conf, method)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build %s encryptor to decrypt with: %s", method, err)
 		}
+		dispatcher.Register(method, encryptor)
+	}
 
-	case "plaintext":
-		enc = plaintext.NewPlaintextEncryptor()
-		log.Println("INF: starting plaintext encryption (default)")
+	return dispatcher, nil
+}
 
-	default:
-		return nil, fmt.Errorf("type '%s' is not supported", conf.EncryptMethod)
+// CloseEncryptor stops the processes started by the encryptor, like the command of a persistent exec encryptor
+func CloseEncryptor(encryptor enc.Encryptor) error {
+	if closer, ok := encryptor.(io.Closer); ok {
+		return closer.Close()
 	}
 
-	return enc, err
+	return nil
+}
+
+func newEncryptor(conf *config.KaigaraConfig, method string) (enc.Encryptor, error) {
+	switch method {
+	case envelope.AlgTransit:
+		return transit.NewVaultEncryptor(conf.VaultAddr, conf.VaultToken)
+
+	case envelope.AlgAES:
+		key, err := loadAESKey(conf)
+		if err != nil {
+			return nil, err
+		}
+
+		return aes.NewAESEncryptor(key)
+
+	case envelope.AlgXChaCha:
+		key, err := loadXChaChaKey(conf)
+		if err != nil {
+			return nil, err
+		}
+
+		return xchacha.NewXChaChaEncryptor(key)
+
+	case envelope.AlgExec:
+		command, err := exec.SplitCommand(conf.ExecCommand)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exec encryptor command: %s", err)
+		}
+
+		return exec.NewExecEncryptor(command, conf.ExecPersistent, conf.ExecTimeout)
+
+	case envelope.AlgPlaintext:
+		return plaintext.NewPlaintextEncryptor(), nil
+
+	default:
+		return nil, fmt.Errorf("type '%s' is not supported", method)
+	}
 }
 
 func CleanAll(ss types.Storage, appNames []string, scopes []string) error {
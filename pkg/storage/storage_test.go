@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openware/kaigara/pkg/config"
+	"github.com/openware/kaigara/pkg/encryptor/envelope"
+	enc "github.com/openware/kaigara/pkg/encryptor/types"
+	"github.com/openware/kaigara/pkg/logger"
+)
+
+func TestNewEncryptorFallbacks(t *testing.T) {
+	conf := &config.KaigaraConfig{
+		EncryptMethod: envelope.AlgAES,
+		AesKey:        "hex:000102030405060708090a0b0c0d0e0f",
+		XChaChaKey:    "hex:000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+	}
+
+	xchachaConf := *conf
+	xchachaConf.EncryptMethod = envelope.AlgXChaCha
+	xchacha, err := NewEncryptor(&xchachaConf)
+	assert.NoError(t, err)
+	ciphertext, err := xchacha.Encrypt("bonjour", "finex")
+	assert.NoError(t, err)
+
+	// Values of other encryptors are only decrypted with explicit fallbacks, plaintext ones never are
+	aes, err := NewEncryptor(conf)
+	assert.NoError(t, err)
+	_, err = aes.Decrypt(ciphertext, "finex")
+	assert.ErrorContains(t, err, "'xchacha20poly1305' encryptor, which is not configured")
+	_, err = aes.Decrypt(envelope.Seal(envelope.AlgPlaintext, "", "injected"), "finex")
+	assert.ErrorContains(t, err, "plaintext value is refused")
+
+	aes, err = NewEncryptor(conf, envelope.AlgXChaCha, envelope.AlgPlaintext)
+	assert.NoError(t, err)
+	plaintext, err := aes.Decrypt(ciphertext, "finex")
+	assert.NoError(t, err)
+	assert.Equal(t, "bonjour", plaintext)
+	_, err = aes.Decrypt(envelope.Seal(envelope.AlgPlaintext, "", "injected"), "finex")
+	assert.ErrorContains(t, err, "plaintext value is refused")
+
+	_, err = NewEncryptor(conf, envelope.AlgExec)
+	assert.ErrorContains(t, err, "failed to build exec encryptor")
+}
+
+func TestWarnK8sPolicy(t *testing.T) {
+	var buf bytes.Buffer
+	defer logger.SetDefault(logger.Default())
+	logger.SetDefault(logger.New(&buf, "Kaigara", logger.InfoLevel, logger.FormatText))
+
+	warnK8sPolicy(enc.NewScopePolicy(nil))
+	assert.Empty(t, buf.String())
+
+	warnK8sPolicy(enc.NewScopePolicy([]string{"secret", "private"}))
+	assert.Contains(t, buf.String(), "WRN: the k8s storage encrypts every scope, the encrypted scopes are ignored encrypted_scopes=[private secret]")
+}
@@ -7,7 +7,7 @@ go 1.17
 require (
 	github.com/hashicorp/vault/api v1.3.1
 	github.com/iancoleman/strcase v0.2.0
-	github.com/openware/kaigara/pkg/encryptor v0.0.0-20220428165818-6271445f8750
+	github.com/openware/kaigara/pkg/encryptor v0.1.0
 	github.com/stretchr/testify v1.7.1
 )
 
@@ -19,10 +19,11 @@ type Service struct {
 	vault        *api.Client
 	deploymentID string // Used as vault prefix
 	encryptor    types.Encryptor
+	policy       types.ScopePolicy
 }
 
 // NewService instantiates a Vault service
-func NewService(deploymentID string, encryptor types.Encryptor, addr, token string) (*Service, error) {
+func NewService(deploymentID string, encryptor types.Encryptor, policy types.ScopePolicy, addr, token string) (*Service, error) {
 	if addr == "" {
 		addr = "http://localhost:8200"
 	}
@@ -49,6 +50,7 @@ func NewService(deploymentID string, encryptor types.Encryptor, addr, token stri
 		deploymentID: deploymentID,
 		vault:        client,
 		encryptor:    encryptor,
+		policy:       policy,
 	}
 
 	err = s.startRenewToken(token)
@@ -153,10 +155,10 @@ func (vs *Service) Read(appName, scope string) error {
 
 // SetEntry stores all secrets into the memory
 func (vs *Service) SetEntry(appName, scope, name string, value interface{}) error {
-	if scope == "secret" {
-		str, ok := value.(string)
-		if !ok {
-			return fmt.Errorf("invalid value for %s, must be a string: %v", name, value)
+	if vs.policy.IsEncrypted(scope) {
+		str, err := types.EncodeValue(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %s", name, err)
 		}
 
 		encrypted, err := vs.encryptor.Encrypt(str, vs.transitKeyName(appName))
@@ -172,13 +174,24 @@ func (vs *Service) SetEntry(appName, scope, name string, value interface{}) erro
 	return nil
 }
 
-// SetEntries inserts given data into the secret store, overwriting keys if they exist
+// SetEntries inserts given data into the secret store, overwriting keys if they exist.
+// The values of encrypted scopes are encrypted at once, so batching encryptors are called only once.
 func (vs *Service) SetEntries(appName, scope string, data map[string]interface{}) error {
-	for k, v := range data {
-		err := vs.SetEntry(appName, scope, k, v)
-		if err != nil {
-			return err
+	scopeSecrets := vs.data[appName][scope].(map[string]interface{})
+	if !vs.policy.IsEncrypted(scope) {
+		for k, v := range data {
+			scopeSecrets[k] = v
 		}
+		return nil
+	}
+
+	encrypted, err := types.EncryptEntries(vs.encryptor, data, vs.transitKeyName(appName))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range encrypted {
+		scopeSecrets[k] = v
 	}
 	return nil
 }
@@ -200,6 +213,16 @@ func (vs *Service) Write(appName, scope string) error {
 
 // GetEntries returns all the secrets currently stored in Vault
 func (vs *Service) GetEntries(appName, scope string) (map[string]interface{}, error) {
+	// Decrypt all the values of encrypted scopes at once, so batching encryptors are called only once
+	if vs.policy.IsEncrypted(scope) {
+		scopeSecrets, ok := vs.data[appName][scope].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("scope '%s' is not a map", scope)
+		}
+
+		return types.DecryptEntries(vs.encryptor, scopeSecrets, vs.transitKeyName(appName))
+	}
+
 	res := make(map[string]interface{})
 	for k := range vs.data[appName][scope].(map[string]interface{}) {
 		val, err := vs.GetEntry(appName, scope, k)
@@ -214,8 +237,8 @@ func (vs *Service) GetEntries(appName, scope string) (map[string]interface{}, er
 
 // GetEntry returns a secret value by name
 func (vs *Service) GetEntry(appName, scope, name string) (interface{}, error) {
-	// Since secret scope only supports strings, return a decrypted string
-	if scope == "secret" {
+	// Values of encrypted scopes are stored as encrypted strings, return the decrypted value
+	if vs.policy.IsEncrypted(scope) {
 		scopeSecrets, ok := vs.data[appName][scope].(map[string]interface{})
 		if !ok {
 			return nil, fmt.Errorf("scope '%s' is not a map", scope)
@@ -228,7 +251,7 @@ func (vs *Service) GetEntry(appName, scope, name string) (interface{}, error) {
 
 		str, ok := rawValue.(string)
 		if !ok {
-			return nil, fmt.Errorf("invalid value for %s, must be a string: %v", name, rawValue)
+			return nil, fmt.Errorf("invalid value for %s, must be an encrypted string, got %T", name, rawValue)
 		}
 
 		decrypted, err := vs.encryptor.Decrypt(str, vs.transitKeyName(appName))
@@ -236,7 +259,7 @@ func (vs *Service) GetEntry(appName, scope, name string) (interface{}, error) {
 			return nil, err
 		}
 
-		return decrypted, nil
+		return types.DecodeValue(decrypted)
 	}
 
 	return vs.data[appName][scope].(map[string]interface{})[name], nil
@@ -285,13 +308,13 @@ func (vs *Service) PushPolicies(policies map[string]string) error {
 
 	for component, rules := range policies {
 		name := fmt.Sprintf("%s_%s", vs.deploymentID, component)
-		fmt.Println("Loading policy", name)
+		types.Log().Info("loading policy", "policy", name)
 		err := vs.vault.Sys().PutPolicy(name, rules)
 		if err != nil {
 			return err
 		}
 
-		fmt.Println("Creating token", name)
+		types.Log().Info("creating token", "policy", name)
 		t := true
 		token, err := vs.vault.Auth().Token().Create(&api.TokenCreateRequest{
 			Policies:  []string{name},
@@ -344,6 +367,19 @@ func (vs *Service) GetLatestVersion(appName, scope string) (int64, error) {
 	return versionNumber, nil
 }
 
+// GetNextVersion returns the version Vault gives to the scope on its next write, versions start at 1
+func (vs *Service) GetNextVersion(appName, scope string) (int64, error) {
+	latest, err := vs.GetLatestVersion(appName, scope)
+	if err != nil {
+		return 0, err
+	}
+	if latest < 0 {
+		latest = 0
+	}
+
+	return latest + 1, nil
+}
+
 // Delete key from Data, Metadata and Vault
 func (vs *Service) DeleteEntry(appName, scope, name string) error {
 	metadata, err := vs.vault.Logical().Delete(vs.keyPath(appName, scope))
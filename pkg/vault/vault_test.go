@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/openware/kaigara/pkg/encryptor/transit"
+	"github.com/openware/kaigara/pkg/encryptor/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,7 +22,7 @@ func TestServiceSetGetSecrets(t *testing.T) {
 	}
 
 	// Initialize Vault SecretStore
-	ss, err := NewService(deploymentID, encryptor, vaultAddr, vaultToken)
+	ss, err := NewService(deploymentID, encryptor, types.NewScopePolicy(nil), vaultAddr, vaultToken)
 	if err != nil {
 		t.Fatal(err)
 	}